@@ -0,0 +1,454 @@
+package valpass
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	AUDIT_CSV  string = "csv"
+	AUDIT_JSON string = "json"
+
+	AUDIT_TOP int = 10 // default number of top dictionary hits and failure reasons
+
+	// maximum length of a line in an audit file
+	audit_max_line int = 1024 * 1024
+)
+
+// AuditOptions configures Validator.Audit().
+type AuditOptions struct {
+	// Workers is the number of passwords validated in parallel,
+	// runtime.NumCPU() if zero.
+	Workers int
+
+	// Report receives a per-line report if set, it never contains the
+	// passwords or the dictionary words they matched.
+	Report io.Writer
+
+	// ReportFormat is either AUDIT_CSV (default) or AUDIT_JSON (one
+	// object per line).
+	ReportFormat string
+
+	// Top is the number of top dictionary hits and failure reasons to
+	// keep, AUDIT_TOP if zero.
+	Top int
+}
+
+// AuditStats contains the aggregated statistics of an audit.
+type AuditStats struct {
+	Total  int // number of passwords, empty lines are skipped
+	Passed int // passwords which passed all checks
+	Failed int // passwords which failed at least one check
+	Errors int // passwords which could not be validated, e.g. invalid UTF-8
+
+	Checks map[string]*CheckStats // failures per check, keyed by check name
+
+	Entropy          Histogram          // entropy in bits/char, if measured
+	Compress         Histogram          // compression rate in percent, if measured
+	CharDistribution Histogram          // character distribution in percent, if measured
+	Scores           [MAX_SCORE + 1]int // passwords per score, if a score has been calculated
+
+	// Most common dictionary words. An exact match is the password
+	// apart from case, so treat the statistics as confidential.
+	DictionaryHits []Count
+	FailureReasons []Count // most common combinations of failed checks, e.g. "chardist+entropy"
+}
+
+// CheckStats contains the results of a single check.
+type CheckStats struct {
+	Failed int     // number of passwords which failed the check
+	Rate   float64 // failed passwords in percent of all validated passwords
+}
+
+// Count is an entry in a top list.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Histogram counts values in buckets of Width, bucket i contains the
+// values from i*Width up to (i+1)*Width.
+type Histogram struct {
+	Width   float64
+	Buckets []int
+}
+
+// AuditLine is an entry of the per-line report, metrics which have
+// not been measured are nil.
+type AuditLine struct {
+	Line             int      `json:"line"`
+	Ok               bool     `json:"ok"`
+	Error            string   `json:"error,omitempty"`
+	Score            *int     `json:"score,omitempty"`
+	Entropy          *float64 `json:"entropy,omitempty"`
+	Compress         *int     `json:"compress,omitempty"`
+	CharDistribution *float64 `json:"chardist,omitempty"`
+	Failures         []string `json:"failures"`
+
+	seq      int    // position in the stream, without empty lines
+	dictword string // the matched dictionary word, only used for the statistics
+}
+
+// a password to validate
+type auditJob struct {
+	seq        int
+	line       int
+	passphrase string
+}
+
+// collects the results in line order
+type auditor struct {
+	options   AuditOptions
+	stats     *AuditStats
+	dictwords map[string]int
+	reasons   map[string]int
+	pending   map[int]AuditLine // results waiting for their predecessors
+	next      int               // the next seq to write
+	csv       *csv.Writer
+	json      *json.Encoder
+}
+
+/*
+Audit validates every line read from reader as a password and returns
+aggregated  statistics. The passwords are  being validated  by a pool
+of workers, the optional per-line report is written in line order.
+Lines which can't be validated are counted as errors, they don't stop
+the audit. Only read errors and report write errors are returned.
+*/
+func (validator *Validator) Audit(reader io.Reader, options AuditOptions) (*AuditStats, error) {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+
+	if options.Top <= 0 {
+		options.Top = AUDIT_TOP
+	}
+
+	audit, err := newAuditor(options)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan auditJob, options.Workers*2)
+	results := make(chan AuditLine, options.Workers*2)
+
+	var workers sync.WaitGroup
+
+	for i := 0; i < options.Workers; i++ {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			for job := range jobs {
+				results <- validator.auditLine(job)
+			}
+		}()
+	}
+
+	// feed the workers, collect the results at the same time
+	var readerr error
+
+	go func() {
+		defer close(jobs)
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 4096), audit_max_line)
+
+		seq := 0
+
+		for line := 1; scanner.Scan(); line++ {
+			passphrase := strings.TrimRight(scanner.Text(), "\r")
+			if passphrase == "" {
+				continue
+			}
+
+			jobs <- auditJob{seq: seq, line: line, passphrase: passphrase}
+			seq++
+		}
+
+		readerr = scanner.Err()
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	var writeerr error
+
+	for result := range results {
+		if err := audit.add(result); err != nil && writeerr == nil {
+			writeerr = err
+		}
+	}
+
+	if readerr != nil {
+		return nil, fmt.Errorf("failed to read passwords: %w", readerr)
+	}
+
+	if writeerr != nil {
+		return nil, fmt.Errorf("failed to write report: %w", writeerr)
+	}
+
+	return audit.result()
+}
+
+// Validate a single password and turn it into a report line.
+func (validator *Validator) auditLine(job auditJob) AuditLine {
+	entry := AuditLine{Line: job.line, Failures: []string{}, seq: job.seq}
+
+	result, err := validator.Validate(job.passphrase)
+	if err != nil {
+		entry.Error = err.Error()
+
+		// the error contains the offending character
+		if errors.Is(err, ErrNonPrintable) {
+			entry.Error = ErrNonPrintable.Error()
+		}
+
+		return entry
+	}
+
+	entry.Ok = result.Ok
+	entry.dictword = result.DictionaryWord
+
+	if result.ScoreLabel != "" {
+		entry.Score = &result.Score
+	}
+
+	for _, failure := range result.Failures {
+		entry.Failures = append(entry.Failures, failure.Check)
+	}
+
+	if _, ok := result.Metrics[CHECK_ENTROPY]; ok {
+		entry.Entropy = &result.Entropy
+	}
+
+	if _, ok := result.Metrics[CHECK_COMPRESS]; ok {
+		entry.Compress = &result.Compress
+	}
+
+	if _, ok := result.Metrics[CHECK_CHARDIST]; ok {
+		entry.CharDistribution = &result.CharDistribution
+	}
+
+	return entry
+}
+
+func newAuditor(options AuditOptions) (*auditor, error) {
+	audit := &auditor{
+		options: options,
+		stats: &AuditStats{
+			Checks:           map[string]*CheckStats{},
+			Entropy:          Histogram{Width: 0.5},
+			Compress:         Histogram{Width: 10},
+			CharDistribution: Histogram{Width: 10},
+		},
+		dictwords: map[string]int{},
+		reasons:   map[string]int{},
+		pending:   map[int]AuditLine{},
+	}
+
+	if options.Report == nil {
+		return audit, nil
+	}
+
+	switch options.ReportFormat {
+	case "", AUDIT_CSV:
+		audit.csv = csv.NewWriter(options.Report)
+		if err := audit.csv.Write([]string{"line", "ok", "error", "score", "entropy", "compress",
+			"chardist", "failures"}); err != nil {
+			return nil, fmt.Errorf("failed to write report: %w", err)
+		}
+	case AUDIT_JSON:
+		audit.json = json.NewEncoder(options.Report)
+	default:
+		return nil, fmt.Errorf("unknown report format: %s", options.ReportFormat)
+	}
+
+	return audit, nil
+}
+
+// Add a result to the statistics and write the report lines which are
+// complete.
+func (audit *auditor) add(entry AuditLine) error {
+	stats := audit.stats
+	stats.Total++
+
+	switch {
+	case entry.Error != "":
+		stats.Errors++
+	case entry.Ok:
+		stats.Passed++
+	default:
+		stats.Failed++
+	}
+
+	if entry.Error == "" {
+		if entry.Score != nil {
+			stats.Scores[*entry.Score]++
+		}
+
+		if entry.Entropy != nil {
+			stats.Entropy.add(*entry.Entropy)
+		}
+
+		if entry.Compress != nil {
+			stats.Compress.add(float64(*entry.Compress))
+		}
+
+		if entry.CharDistribution != nil {
+			stats.CharDistribution.add(*entry.CharDistribution)
+		}
+	}
+
+	if entry.dictword != "" {
+		audit.dictwords[entry.dictword]++
+	}
+
+	if len(entry.Failures) > 0 {
+		checks := map[string]bool{}
+		for _, check := range entry.Failures {
+			checks[check] = true
+		}
+
+		reason := []string{}
+		for check := range checks {
+			reason = append(reason, check)
+
+			if stats.Checks[check] == nil {
+				stats.Checks[check] = &CheckStats{}
+			}
+
+			stats.Checks[check].Failed++
+		}
+
+		sort.Strings(reason)
+		audit.reasons[strings.Join(reason, "+")]++
+	}
+
+	return audit.write(entry)
+}
+
+// Write the report in line order, results arrive in any order.
+func (audit *auditor) write(entry AuditLine) error {
+	if audit.csv == nil && audit.json == nil {
+		return nil
+	}
+
+	audit.pending[entry.seq] = entry
+
+	for {
+		next, ok := audit.pending[audit.next]
+		if !ok {
+			return nil
+		}
+
+		delete(audit.pending, audit.next)
+		audit.next++
+
+		if err := audit.writeLine(next); err != nil {
+			return err
+		}
+	}
+}
+
+func (audit *auditor) writeLine(entry AuditLine) error {
+	if audit.json != nil {
+		return audit.json.Encode(entry)
+	}
+
+	var score, entropy, compress, dist string
+
+	if entry.Score != nil {
+		score = strconv.Itoa(*entry.Score)
+	}
+
+	if entry.Entropy != nil {
+		entropy = strconv.FormatFloat(*entry.Entropy, 'f', 2, 64)
+	}
+
+	if entry.Compress != nil {
+		compress = strconv.Itoa(*entry.Compress)
+	}
+
+	if entry.CharDistribution != nil {
+		dist = strconv.FormatFloat(*entry.CharDistribution, 'f', 2, 64)
+	}
+
+	return audit.csv.Write([]string{
+		strconv.Itoa(entry.Line),
+		strconv.FormatBool(entry.Ok),
+		entry.Error,
+		score,
+		entropy,
+		compress,
+		dist,
+		strings.Join(entry.Failures, ";"),
+	})
+}
+
+// Flush the report and return the final statistics.
+func (audit *auditor) result() (*AuditStats, error) {
+	if audit.csv != nil {
+		audit.csv.Flush()
+
+		if err := audit.csv.Error(); err != nil {
+			return nil, fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	stats := audit.stats
+	validated := stats.Total - stats.Errors
+
+	for _, check := range stats.Checks {
+		check.Rate = float64(check.Failed) * 100 / float64(Max(validated, 1))
+	}
+
+	stats.DictionaryHits = topCounts(audit.dictwords, audit.options.Top)
+	stats.FailureReasons = topCounts(audit.reasons, audit.options.Top)
+
+	return stats, nil
+}
+
+func (histogram *Histogram) add(value float64) {
+	bucket := Max(int(value/histogram.Width), 0)
+
+	for len(histogram.Buckets) <= bucket {
+		histogram.Buckets = append(histogram.Buckets, 0)
+	}
+
+	histogram.Buckets[bucket]++
+}
+
+// Returns the top entries, sorted by count and name.
+func topCounts(counts map[string]int, top int) []Count {
+	list := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, Count{Name: name, Count: count})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+
+		return list[i].Name < list[j].Name
+	})
+
+	if len(list) > top {
+		list = list[:top]
+	}
+
+	return list
+}
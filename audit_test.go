@@ -0,0 +1,136 @@
+package valpass_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.DefaultOptions()
+	opts.Dictionary = opts_dict.Dictionary
+
+	validator, err := valpass.NewValidator(opts)
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	passwords := append(append([]string{}, pass_random_good...), pass_worst_bad...)
+	passwords = append(passwords, `clock`, `Clock`)
+	dump := strings.Join(passwords, "\n") + "\n\n" + "abc\x07def\r\n"
+
+	for _, format := range []string{valpass.AUDIT_CSV, valpass.AUDIT_JSON} {
+		var report bytes.Buffer
+
+		stats, err := validator.Audit(strings.NewReader(dump),
+			valpass.AuditOptions{Workers: 4, Report: &report, ReportFormat: format, Top: 3})
+		if err != nil {
+			t.Fatalf("audit failed: %s", err)
+		}
+
+		if stats.Total != len(passwords)+1 || stats.Passed != len(pass_random_good) ||
+			stats.Failed != len(pass_worst_bad)+2 || stats.Errors != 1 {
+			t.Errorf("unexpected totals: %+v", stats)
+		}
+
+		entropy := 0
+		for _, count := range stats.Entropy.Buckets {
+			entropy += count
+		}
+
+		scored := 0
+		for _, count := range stats.Scores {
+			scored += count
+		}
+
+		if entropy != len(passwords) || scored != len(passwords) || stats.Checks[valpass.CHECK_DICTIONARY].Failed == 0 ||
+			stats.Checks[valpass.CHECK_ENTROPY].Rate <= 0 {
+			t.Errorf("unexpected statistics: %+v", stats)
+		}
+
+		if len(stats.DictionaryHits) != 3 || stats.DictionaryHits[0].Name != "clock" ||
+			stats.DictionaryHits[0].Count != 2 || len(stats.FailureReasons) != 3 {
+			t.Errorf("unexpected top lists: %v %v", stats.DictionaryHits, stats.FailureReasons)
+		}
+
+		lines := []int{}
+
+		switch format {
+		case valpass.AUDIT_CSV:
+			records, err := csv.NewReader(&report).ReadAll()
+			if err != nil {
+				t.Fatalf("invalid CSV report: %s", err)
+			}
+
+			for _, record := range records[1:] {
+				line, _ := strconv.Atoi(record[0])
+				lines = append(lines, line)
+			}
+		case valpass.AUDIT_JSON:
+			decoder := json.NewDecoder(&report)
+
+			for decoder.More() {
+				var entry valpass.AuditLine
+				if err := decoder.Decode(&entry); err != nil {
+					t.Fatalf("invalid JSON report: %s", err)
+				}
+
+				lines = append(lines, entry.Line)
+			}
+		}
+
+		// in order, the empty line has been skipped
+		for i, line := range lines {
+			want := i + 1
+			if i == len(passwords) {
+				want++
+			}
+
+			if line != want {
+				t.Fatalf("%s report is out of order at %d: %v", format, i, lines)
+			}
+		}
+
+		if len(lines) != len(passwords)+1 {
+			t.Errorf("%s report has %d lines, want %d", format, len(lines), len(passwords)+1)
+		}
+
+		// neither the passwords nor the dictionary words they matched
+		for _, pass := range append(pass_random_good, `clock`, `Clock`) {
+			if strings.Contains(report.String(), pass) {
+				t.Errorf("%s report contains password %s", format, pass)
+			}
+		}
+	}
+
+	// nothing has been scored, nothing is counted, policy violations
+	// would cap the score
+	unscored, err := valpass.NewValidator(valpass.Options{MaxLength: 1024})
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	var report bytes.Buffer
+
+	stats, err := unscored.Audit(strings.NewReader(dump),
+		valpass.AuditOptions{Report: &report, ReportFormat: valpass.AUDIT_JSON})
+	if err != nil {
+		t.Fatalf("audit failed: %s", err)
+	}
+
+	if stats.Scores != [valpass.MAX_SCORE + 1]int{} || strings.Contains(report.String(), `"score"`) {
+		t.Errorf("unscored passwords counted: %v", stats.Scores)
+	}
+
+	if _, err := validator.Audit(strings.NewReader(dump), valpass.AuditOptions{
+		Report: &bytes.Buffer{}, ReportFormat: "xml"}); err == nil {
+		t.Errorf("unknown report format not detected")
+	}
+}
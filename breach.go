@@ -0,0 +1,265 @@
+package valpass
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// length of a hex encoded SHA-1 hash
+	sha1_hex_len int = 40
+
+	// length of the hash prefix sent to range APIs
+	range_prefix_len int = 5
+
+	HIBP_RANGE_URL string        = "https://api.pwnedpasswords.com/range/"
+	RANGE_TIMEOUT  time.Duration = 5 * time.Second
+)
+
+// ErrBreachLookup  is returned  (wrapped) if  a breach  checker could
+// not  perform  the lookup, e.g.  because  of  network errors.  This
+// is different  from a  password being  found, which is  reported in
+// the Result.
+var ErrBreachLookup = errors.New("breach lookup failed")
+
+// BreachChecker  can be  implemented  to  lookup passwords  in breach
+// databases. BreachCount returns how often  the password has been seen
+// in breaches, 0 if never.
+type BreachChecker interface {
+	BreachCount(passphrase string) (int, error)
+}
+
+// breachFile is a local HIBP file, see getBreachFileCount().
+type breachFile string
+
+func (path breachFile) BreachCount(passphrase string) (int, error) {
+	count, err := getBreachFileCount(string(path), passphrase)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBreachLookup, err)
+	}
+
+	return count, nil
+}
+
+// RangeClient  is a  BreachChecker  which  speaks the  k-anonymity
+// range protocol  of Have  I Been Pwned.  Only the first  5 hex chars
+// of the SHA-1 hash of a  password are being sent to the server, the
+// returned suffixes are matched locally.
+type RangeClient struct {
+	// BaseURL is the range endpoint, the hash prefix is appended to it.
+	BaseURL string
+
+	// Client is the HTTP client to use.
+	Client *http.Client
+
+	// Timeout limits the duration of a single lookup.
+	Timeout time.Duration
+
+	// Padding asks the server to pad the response with fake entries,
+	// so that the response size doesn't reveal the prefix.
+	Padding bool
+}
+
+// NewRangeClient returns a new RangeClient for the public HIBP API.
+//
+// Default options:
+//
+//	BaseURL: HIBP_RANGE_URL
+//	Client: http.DefaultClient
+//	Timeout: RANGE_TIMEOUT
+//	Padding: true
+func NewRangeClient() *RangeClient {
+	return &RangeClient{
+		BaseURL: HIBP_RANGE_URL,
+		Client:  http.DefaultClient,
+		Timeout: RANGE_TIMEOUT,
+		Padding: true,
+	}
+}
+
+// BreachCount  looks up  the password  using the  range API.  Network
+// and protocol errors are wrapped into ErrBreachLookup.
+func (client *RangeClient) BreachCount(passphrase string) (int, error) {
+	hash := getSHA1(passphrase)
+	prefix, suffix := hash[:range_prefix_len], hash[range_prefix_len:]
+
+	ctx := context.Background()
+	if client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.Timeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, client.BaseURL+prefix, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBreachLookup, err)
+	}
+
+	request.Header.Set("User-Agent", "valpass")
+	if client.Padding {
+		request.Header.Set("Add-Padding", "true")
+	}
+
+	httpclient := client.Client
+	if httpclient == nil {
+		httpclient = http.DefaultClient
+	}
+
+	response, err := httpclient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBreachLookup, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: unexpected response status %s", ErrBreachLookup, response.Status)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.EqualFold(strings.SplitN(line, ":", 2)[0], suffix) {
+			continue
+		}
+
+		// padding entries have a count of 0, so no special treatment
+		count, err := parseBreachCount(line)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrBreachLookup, err)
+		}
+
+		return count, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBreachLookup, err)
+	}
+
+	return 0, nil
+}
+
+// Returns the upper cased hex SHA-1 hash of the password, which is the
+// format used by Have I Been Pwned.
+func getSHA1(passphrase string) string {
+	sum := sha1.Sum([]byte(passphrase))
+
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+/*
+Lookup the password in a  local breach file in the Have I Been Pwned
+format, that is, one "SHA1:count" entry per line, sorted by hash. The
+file is binary searched on disk, it is never loaded into memory.
+
+Returns the breach count, which is 0 if the password is not listed.
+*/
+func getBreachFileCount(path string, passphrase string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open breach file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat breach file: %w", err)
+	}
+
+	hash := getSHA1(passphrase)
+
+	// invariant: if the hash is listed, its line starts within [low, high)
+	low, high := int64(0), info.Size()
+
+	for low < high {
+		mid := low + (high-low)/2
+
+		line, next, err := readLineAt(file, mid, info.Size())
+		if err != nil {
+			return 0, err
+		}
+
+		if line == "" {
+			// no line starts at or after mid
+			high = mid
+			continue
+		}
+
+		if len(line) < sha1_hex_len {
+			return 0, fmt.Errorf("invalid breach file entry at offset %d", mid)
+		}
+
+		switch strings.Compare(strings.ToUpper(line[:sha1_hex_len]), hash) {
+		case 0:
+			return parseBreachCount(line)
+		case -1:
+			low = next
+		default:
+			high = mid
+		}
+	}
+
+	return 0, nil
+}
+
+/*
+Read the first line starting at or after offset. Returns the line
+without line endings and the offset of the line following it. An empty
+line is returned if there is no such line.
+*/
+func readLineAt(file *os.File, offset, size int64) (string, int64, error) {
+	start := offset
+	if start > 0 {
+		// we might be in the middle of a line, so skip to the next one
+		start--
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, start, size-start))
+
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return "", size, nil
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to read breach file: %w", err)
+		}
+
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("failed to read breach file: %w", err)
+	}
+
+	next := start + int64(len(line))
+
+	return strings.TrimRight(line, "\r\n"), next, nil
+}
+
+// Parse the count of a "SHA1:count" line, a missing count means 1.
+func parseBreachCount(line string) (int, error) {
+	_, count, found := strings.Cut(line, ":")
+	if !found {
+		return 1, nil
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return 0, fmt.Errorf("invalid breach count %q: %w", count, err)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,210 @@
+package valpass_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tlinden/valpass"
+)
+
+var pass_breached = map[string]int{
+	`123456`:    37359195,
+	`password`:  9545824,
+	`qwerty123`: 1234,
+	`trustno1`:  3,
+	`letmein`:   1,
+}
+
+// fixedBreach reports the same breach count for every password.
+type fixedBreach int
+
+func (count fixedBreach) BreachCount(string) (int, error) {
+	return int(count), nil
+}
+
+// Write a sorted breach file containing the given passwords and some
+// filler entries, using the given line ending.
+func WriteBreachFile(t *testing.T, passwords map[string]int, eol string) string {
+	lines := []string{}
+
+	for pass, count := range passwords {
+		sum := sha1.Sum([]byte(pass))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), count))
+	}
+
+	for i := 0; i < 1000; i++ {
+		sum := sha1.Sum([]byte(fmt.Sprintf("filler-%d", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, eol)+eol), 0600); err != nil {
+		t.Fatalf("failed to write breach file: %s", err)
+	}
+
+	return path
+}
+
+func TestBreachFile(t *testing.T) {
+	t.Parallel()
+
+	for _, eol := range []string{"\n", "\r\n"} {
+		opts := valpass.Options{BreachFile: WriteBreachFile(t, pass_breached, eol)}
+
+		for pass, count := range pass_breached {
+			result, err := valpass.Validate(pass, opts)
+			if err != nil {
+				t.Fatalf("breach lookup failed with error: %s", err)
+			}
+
+			if result.Ok || !result.Breached || result.BreachCount != count {
+				t.Errorf("breach lookup of %s failed. want count: %d, got: %v", pass, count, result)
+			}
+		}
+
+		for _, pass := range pass_random_good {
+			result, err := valpass.Validate(pass, opts)
+			if err != nil {
+				t.Fatalf("breach lookup failed with error: %s", err)
+			}
+
+			if !result.Ok || result.Breached {
+				t.Errorf("breach lookup of %s found a hit: %v", pass, result)
+			}
+		}
+	}
+}
+
+func TestBreachFileMinCount(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.Options{
+		BreachFile:     WriteBreachFile(t, pass_breached, "\n"),
+		BreachMinCount: 100,
+	}
+
+	result, err := valpass.Validate(`trustno1`, opts)
+	if err != nil {
+		t.Fatalf("breach lookup failed with error: %s", err)
+	}
+
+	if !result.Ok || !result.Breached || result.BreachCount != 3 {
+		t.Errorf("password below minimum count has been flagged: %v", result)
+	}
+
+	result, err = valpass.Validate(`qwerty123`, opts)
+	if err != nil {
+		t.Fatalf("breach lookup failed with error: %s", err)
+	}
+
+	if result.Ok {
+		t.Errorf("password above minimum count has not been flagged: %v", result)
+	}
+}
+
+func TestBreachFileMissing(t *testing.T) {
+	t.Parallel()
+
+	_, err := valpass.Validate(`password`, valpass.Options{BreachFile: "t/does-not-exist"})
+	if !errors.Is(err, valpass.ErrBreachLookup) {
+		t.Errorf("breach lookup in missing file did not fail properly: %v", err)
+	}
+}
+
+// Range API stand-in serving the given passwords plus padding entries.
+func NewRangeServer(t *testing.T, passwords map[string]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		if len(prefix) != 5 {
+			t.Errorf("range request sent more than the hash prefix: %s", r.URL.Path)
+			http.Error(w, "invalid prefix", http.StatusBadRequest)
+			return
+		}
+
+		for pass, count := range passwords {
+			sum := sha1.Sum([]byte(pass))
+			hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+			if strings.HasPrefix(hash, prefix) {
+				fmt.Fprintf(w, "%s:%d\r\n", hash[5:], count)
+			}
+		}
+
+		if r.Header.Get("Add-Padding") == "true" {
+			fmt.Fprintf(w, "%s:0\r\n", strings.Repeat("F", 35))
+		}
+	}))
+}
+
+func TestRangeClient(t *testing.T) {
+	t.Parallel()
+
+	server := NewRangeServer(t, pass_breached)
+	defer server.Close()
+
+	client := valpass.NewRangeClient()
+	client.BaseURL = server.URL + "/range/"
+	client.Client = server.Client()
+
+	opts := valpass.Options{Breach: client}
+
+	for pass, count := range pass_breached {
+		result, err := valpass.Validate(pass, opts)
+		if err != nil {
+			t.Fatalf("range lookup failed with error: %s", err)
+		}
+
+		if result.Ok || !result.Breached || result.BreachCount != count {
+			t.Errorf("range lookup of %s failed. want count: %d, got: %v", pass, count, result)
+		}
+	}
+
+	result, err := valpass.Validate(pass_random_good[0], opts)
+	if err != nil {
+		t.Fatalf("range lookup failed with error: %s", err)
+	}
+
+	if !result.Ok || result.Breached {
+		t.Errorf("range lookup of a good password found a hit: %v", result)
+	}
+}
+
+func TestRangeClientErrors(t *testing.T) {
+	t.Parallel()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, server := range []*httptest.Server{failing, slow, closed} {
+		client := valpass.NewRangeClient()
+		client.BaseURL = server.URL + "/range/"
+		client.Timeout = 50 * time.Millisecond
+
+		_, err := valpass.Validate(`password`, valpass.Options{Breach: client})
+		if !errors.Is(err, valpass.ErrBreachLookup) {
+			t.Errorf("range lookup against %s did not fail properly: %v", server.URL, err)
+		}
+	}
+}
@@ -0,0 +1,690 @@
+package valpass
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+Check can be implemented to add custom metrics to valpass. Name returns
+a stable identifier, which is being used in Failure.Check and as the key
+in Result.Metrics. Run measures the password and contributes to the
+result: use Result.SetMetric() to record measured values and
+Result.Fail() to flag the password. An error aborts the validation.
+
+Custom checks are being supplied in Options.Checks, they run in order
+after the built-in ones. The built-in checks are available as Check
+types as well, see BuiltinChecks() and Options.Builtin to reorder or
+replace them. Checks used by a Validator must be safe for concurrent
+use.
+*/
+type Check interface {
+	Name() string
+	Run(passphrase string, result *Result) error
+}
+
+// EntropyCheck flags passwords whose entropy is not higher than Min
+// bits/char. Passphrases detected by a PassphraseCheck running before
+// are skipped, a per char metric doesn't apply to words.
+type EntropyCheck struct {
+	Min float64
+}
+
+func (check EntropyCheck) Name() string {
+	return CHECK_ENTROPY
+}
+
+func (check EntropyCheck) Run(passphrase string, result *Result) error {
+	if result.Passphrase {
+		return nil
+	}
+
+	entropy, err := getEntropy(passphrase)
+	if err != nil {
+		return err
+	}
+
+	if entropy <= check.Min {
+		result.Fail(CHECK_ENTROPY, check.Min, entropy,
+			fmt.Sprintf("password entropy of %.2f bits/char is too low, it must be higher than %.2f",
+				entropy, check.Min))
+	}
+
+	result.Entropy = entropy
+	result.SetMetric(CHECK_ENTROPY, entropy)
+
+	return nil
+}
+
+// TotalEntropyCheck flags passwords whose entropy in bits/char times
+// their length is below Min bits.
+type TotalEntropyCheck struct {
+	Min float64
+}
+
+func (check TotalEntropyCheck) Name() string {
+	return CHECK_TOTAL
+}
+
+func (check TotalEntropyCheck) Run(passphrase string, result *Result) error {
+	entropy, err := getEntropy(passphrase)
+	if err != nil {
+		return err
+	}
+
+	total := entropy * float64(utf8.RuneCountInString(passphrase))
+
+	if total < check.Min {
+		result.Fail(CHECK_TOTAL, check.Min, total,
+			fmt.Sprintf("password entropy of %.2f bits is too low, it must be at least %.2f bits",
+				total, check.Min))
+	}
+
+	result.TotalEntropy = total
+	result.SetMetric(CHECK_TOTAL, total)
+
+	return nil
+}
+
+// PoolEntropyCheck flags passwords whose length times log2 of the size
+// of the character classes used is below Min bits.
+type PoolEntropyCheck struct {
+	Min float64
+}
+
+func (check PoolEntropyCheck) Name() string {
+	return CHECK_POOL
+}
+
+func (check PoolEntropyCheck) Run(passphrase string, result *Result) error {
+	if err := checkPrintable(passphrase); err != nil {
+		return err
+	}
+
+	pool := getPoolEntropy(passphrase)
+
+	if pool < check.Min {
+		result.Fail(CHECK_POOL, check.Min, pool,
+			fmt.Sprintf("password strength of %.2f bits is too low, it must be at least %.2f bits, "+
+				"use a longer password or more kinds of characters", pool, check.Min))
+	}
+
+	result.PoolEntropy = pool
+	result.SetMetric(CHECK_POOL, pool)
+
+	return nil
+}
+
+// CompressCheck flags passwords which can be compressed by Max percent
+// (MIN_COMPRESS if zero) or more by any of the Compressors, a
+// FlateCompressor if empty.
+type CompressCheck struct {
+	Max         int
+	Compressors []Compressor
+}
+
+func (check CompressCheck) Name() string {
+	return CHECK_COMPRESS
+}
+
+func (check CompressCheck) Run(passphrase string, result *Result) error {
+	if check.Max == 0 {
+		check.Max = MIN_COMPRESS
+	}
+
+	compressors := check.Compressors
+	if len(compressors) == 0 {
+		compressors = []Compressor{FlateCompressor{}}
+	}
+
+	compression := 0
+	worst := compressors[0].Name()
+	result.Compressions = map[string]int{}
+
+	for _, compressor := range compressors {
+		rate, err := getCompression([]byte(passphrase), compressor)
+		if err != nil {
+			return err
+		}
+
+		result.Compressions[compressor.Name()] = rate
+
+		if rate > compression {
+			compression = rate
+			worst = compressor.Name()
+		}
+	}
+
+	if compression >= check.Max {
+		using := ""
+		if len(compressors) > 1 {
+			using = " using " + worst
+		}
+
+		result.Fail(CHECK_COMPRESS, float64(check.Max), float64(compression),
+			fmt.Sprintf("password can be compressed by %d%%%s, it must be less than %d%%",
+				compression, using, check.Max))
+	}
+
+	result.Compress = compression
+	result.SetMetric(CHECK_COMPRESS, float64(compression))
+
+	return nil
+}
+
+// ExplainedCheck flags passwords which are explained by Max percent
+// (MAX_EXPLAINED if zero) or more by words of the Dictionary and the
+// UserInputs, see Options.Explained.
+type ExplainedCheck struct {
+	Max        int
+	Dictionary *Dictionary
+	UserInputs []string
+}
+
+func (check ExplainedCheck) Name() string {
+	return CHECK_EXPLAINED
+}
+
+func (check ExplainedCheck) Run(passphrase string, result *Result) error {
+	if check.Max == 0 {
+		check.Max = MAX_EXPLAINED
+	}
+
+	explained, err := getExplained(passphrase, check.Dictionary, check.UserInputs)
+	if err != nil {
+		return err
+	}
+
+	if explained >= check.Max {
+		result.Fail(CHECK_EXPLAINED, float64(check.Max), float64(explained),
+			fmt.Sprintf("password consists of known words by %d%%, it must be less than %d%%",
+				explained, check.Max))
+	}
+
+	result.Explained = explained
+	result.SetMetric(CHECK_EXPLAINED, float64(explained))
+
+	return nil
+}
+
+// DistributionCheck flags passwords whose character distribution is not
+// higher than Min percent of an alphabet of AlphabetSize chars,
+// MAX_CHARS if zero. Passphrases are skipped, see EntropyCheck.
+type DistributionCheck struct {
+	Min          float64
+	AlphabetSize int
+}
+
+func (check DistributionCheck) Name() string {
+	return CHECK_CHARDIST
+}
+
+func (check DistributionCheck) Run(passphrase string, result *Result) error {
+	if result.Passphrase {
+		return nil
+	}
+
+	dist := getDistribution(passphrase, check.AlphabetSize)
+
+	if dist <= check.Min {
+		result.Fail(CHECK_CHARDIST, check.Min, dist,
+			fmt.Sprintf("password character distribution of %.2f%% is too low, it must be higher than %.2f%%",
+				dist, check.Min))
+	}
+
+	result.CharDistribution = dist
+	result.SetMetric(CHECK_CHARDIST, dist)
+
+	return nil
+}
+
+// DictionaryCheck flags passwords found in the Dictionary. The metric
+// is the Levenshtein distance to the closest word, -1 if none matched.
+type DictionaryCheck struct {
+	Dictionary *Dictionary
+}
+
+func (check DictionaryCheck) Name() string {
+	return CHECK_DICTIONARY
+}
+
+func (check DictionaryCheck) Run(passphrase string, result *Result) error {
+	if check.Dictionary == nil {
+		return fmt.Errorf("dictionary check requires a dictionary")
+	}
+
+	match, err := getDictMatch(passphrase, check.Dictionary)
+	if err != nil {
+		return err
+	}
+
+	if !match.found {
+		result.SetMetric(CHECK_DICTIONARY, -1)
+		return nil
+	}
+
+	result.Fail(CHECK_DICTIONARY, float64(check.Dictionary.fuzzyDistance()),
+		float64(match.distance), match.message())
+	result.DictionaryMatch = true
+	result.DictionaryWord = match.word
+	result.DictionaryDistance = match.distance
+	result.DictionarySimilarity = match.similarity
+	result.DictionaryLeet = match.substitutions
+	result.SetMetric(CHECK_DICTIONARY, float64(match.distance))
+
+	return nil
+}
+
+/*
+PolicyCheck applies the length and character class rules, see the
+fields of the same name in Options. Each rule which has been violated
+is recorded as a separate failure. It also records Result.Length and
+Result.Classes, therefore BuiltinChecks() always includes it.
+*/
+type PolicyCheck struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinClasses    int
+}
+
+func (check PolicyCheck) Name() string {
+	return CHECK_POLICY
+}
+
+func (check PolicyCheck) Run(passphrase string, result *Result) error {
+	checkPolicy(passphrase, check, result)
+
+	return nil
+}
+
+/*
+PassphraseCheck detects passphrases made of words of the Dictionary and
+applies the word based thresholds, see Options.Passphrase. It sets
+Result.Passphrase, which makes the per char checks running afterwards
+skip the password.
+
+The checks returned by BuiltinChecks() prepare the dictionary lookups
+once, a PassphraseCheck you create yourself does it on every Run()
+unless the Dictionary is compiled.
+*/
+type PassphraseCheck struct {
+	Dictionary *Dictionary
+	Words      int     // PASSPHRASE_WORDS if zero
+	WordLength int     // PASSPHRASE_WORD_LENGTH if zero
+	Entropy    float64 // PASSPHRASE_ENTROPY if zero
+
+	words func() *wordSet // prepared lookups, see BuiltinChecks()
+}
+
+func (check PassphraseCheck) Name() string {
+	return CHECK_PASSPHRASE
+}
+
+func (check PassphraseCheck) Run(passphrase string, result *Result) error {
+	return checkPassphrase(passphrase, check, result)
+}
+
+// UserInputCheck flags passwords based on the UserInputs, using the
+// l33t table and fuzzy thresholds of the Dictionary, if any.
+type UserInputCheck struct {
+	UserInputs []string
+	Dictionary *Dictionary
+}
+
+func (check UserInputCheck) Name() string {
+	return CHECK_USERINPUT
+}
+
+func (check UserInputCheck) Run(passphrase string, result *Result) error {
+	result.UserInputMatches = getUserInputMatches(passphrase, check.UserInputs, check.Dictionary)
+
+	if len(result.UserInputMatches) > 0 {
+		result.Fail(CHECK_USERINPUT, 0, float64(len(result.UserInputMatches)),
+			result.UserInputMatches[0].message())
+	}
+
+	return nil
+}
+
+// KeyboardCheck flags keyboard walks of at least Min keys
+// (MIN_KEYBOARD_WALK if zero) on the Layouts, all registered ones if
+// empty.
+type KeyboardCheck struct {
+	Min     int
+	Layouts []string
+}
+
+func (check KeyboardCheck) Name() string {
+	return CHECK_KEYBOARD
+}
+
+func (check KeyboardCheck) Run(passphrase string, result *Result) error {
+	if check.Min == 0 {
+		check.Min = MIN_KEYBOARD_WALK
+	}
+
+	walks, err := getKeyboardWalks(passphrase, check.Layouts)
+	if err != nil {
+		return err
+	}
+
+	result.KeyboardWalks = walks
+
+	if longest := longestWalk(walks); longest.Length >= check.Min {
+		result.Fail(CHECK_KEYBOARD, float64(check.Min), float64(longest.Length),
+			fmt.Sprintf("password contains the keyboard pattern %q", longest.Token))
+	}
+
+	return nil
+}
+
+// SequenceCheck flags sequences and repetitions of at least Min chars,
+// MIN_SEQUENCE if zero.
+type SequenceCheck struct {
+	Min int
+}
+
+func (check SequenceCheck) Name() string {
+	return CHECK_SEQUENCE
+}
+
+func (check SequenceCheck) Run(passphrase string, result *Result) error {
+	if check.Min == 0 {
+		check.Min = MIN_SEQUENCE
+	}
+
+	result.Sequences = getSequences(passphrase)
+
+	if longest := longestSequence(result.Sequences); longest.Length >= check.Min {
+		result.Fail(CHECK_SEQUENCE, float64(check.Min), float64(longest.Length), longest.message())
+	}
+
+	return nil
+}
+
+// DateCheck flags dates and years between MinYear and MaxYear, the
+// defaults DATE_MIN_YEAR and DATE_MAX_YEAR if zero.
+type DateCheck struct {
+	MinYear int
+	MaxYear int
+}
+
+func (check DateCheck) Name() string {
+	return CHECK_DATE
+}
+
+func (check DateCheck) Run(passphrase string, result *Result) error {
+	result.Dates = getDates(passphrase, check.MinYear, check.MaxYear)
+
+	if len(result.Dates) > 0 {
+		result.Fail(CHECK_DATE, 0, float64(len(result.Dates)), result.Dates[0].message())
+	}
+
+	return nil
+}
+
+/*
+GuessesCheck flags passwords which can be cracked with less than 10^Min
+guesses. The estimator considers the Dictionary, the UserInputs,
+keyboard walks on the KeyboardLayouts, sequences and dates between
+MinYear and MaxYear.
+
+The checks returned by BuiltinChecks() prepare the dictionary lookups
+once. If you create a GuessesCheck yourself, they are prepared on every
+Run(), which is expensive for an uncompiled Dictionary.
+*/
+type GuessesCheck struct {
+	Min             float64
+	Dictionary      *Dictionary
+	UserInputs      []string
+	KeyboardLayouts []string
+	MinYear         int
+	MaxYear         int
+
+	dicts func() []guessDict // prepared lookups, see BuiltinChecks()
+}
+
+func (check GuessesCheck) Name() string {
+	return CHECK_GUESSES
+}
+
+func (check GuessesCheck) Run(passphrase string, result *Result) error {
+	dicts := check.dicts
+	if dicts == nil {
+		dicts = getGuessDicts(check.Dictionary, check.UserInputs, nil)
+	}
+
+	result.Guesses = getGuesses(passphrase, guessConfig{
+		dicts:   dicts(),
+		layouts: check.KeyboardLayouts,
+		minyear: check.MinYear,
+		maxyear: check.MaxYear,
+	})
+	result.GuessesLog10 = math.Log10(result.Guesses)
+	result.CrackTimes = getCrackTimes(result.Guesses)
+	result.SetMetric(CHECK_GUESSES, result.GuessesLog10)
+
+	if result.GuessesLog10 < check.Min {
+		result.Fail(CHECK_GUESSES, check.Min, result.GuessesLog10,
+			fmt.Sprintf("password is too easy to guess, it could be cracked within %s",
+				humanDuration(result.CrackTimes.OfflineSlowHash)))
+	}
+
+	return nil
+}
+
+// HistoryCheck flags passwords which have been used before, or a near
+// variant of them, see Options.History.
+type HistoryCheck struct {
+	History HistoryStore
+}
+
+func (check HistoryCheck) Name() string {
+	return CHECK_HISTORY
+}
+
+func (check HistoryCheck) Run(passphrase string, result *Result) error {
+	if check.History == nil {
+		return fmt.Errorf("history check requires a history store")
+	}
+
+	kind, err := getHistoryMatch(passphrase, check.History)
+	if err != nil {
+		return err
+	}
+
+	if kind == "" {
+		return nil
+	}
+
+	result.Reused = true
+	result.ReusedKind = kind
+
+	message := "password is too similar to a previously used one"
+	if kind == HISTORY_EXACT {
+		message = "password has been used before"
+	}
+
+	result.Fail(CHECK_HISTORY, 0, 1, message)
+
+	return nil
+}
+
+// BreachCheck flags passwords which have been seen at least MinCount
+// times (1 if zero) by any of the Checkers. The highest count is
+// reported.
+type BreachCheck struct {
+	Checkers []BreachChecker
+	MinCount int
+}
+
+func (check BreachCheck) Name() string {
+	return CHECK_BREACH
+}
+
+func (check BreachCheck) Run(passphrase string, result *Result) error {
+	for _, checker := range check.Checkers {
+		count, err := checker.BreachCount(passphrase)
+		if err != nil {
+			return err
+		}
+
+		if count > result.BreachCount {
+			result.Breached = true
+			result.BreachCount = count
+		}
+	}
+
+	mincount := Max(check.MinCount, 1)
+
+	if result.Breached && result.BreachCount >= mincount {
+		result.Fail(CHECK_BREACH, float64(mincount), float64(result.BreachCount),
+			fmt.Sprintf("password has been seen %d times in data breaches", result.BreachCount))
+	}
+
+	return nil
+}
+
+/*
+Returns a function which prepares the dictionaries for the guess
+estimator once: the dictionary and the user inputs as a small
+dictionary of their own. An uncompiled dictionary is converted into a
+hash set, words may supply an already prepared one.
+*/
+func getGuessDicts(dict *Dictionary, inputs []string, words func() *wordSet) func() []guessDict {
+	return sync.OnceValue(func() []guessDict {
+		dicts := []guessDict{}
+
+		if dict != nil {
+			if words == nil {
+				words = dict.wordSet
+			}
+
+			dicts = append(dicts, guessDict{dict: dict, words: words()})
+		}
+
+		if len(inputs) > 0 {
+			table, _ := getUserSettings(dict)
+			userdict := getUserDictionary(inputs, table)
+			dicts = append(dicts, guessDict{dict: userdict, words: userdict.wordSet()})
+		}
+
+		return dicts
+	})
+}
+
+/*
+BuiltinChecks returns the built-in checks enabled in the options, in
+the order Validate() runs them. Use it as a starting point to reorder,
+replace or remove built-in checks and put the result into
+Options.Builtin.
+*/
+func BuiltinChecks(options Options) []Check {
+	checks := []Check{PolicyCheck{
+		MinLength:     options.MinLength,
+		MaxLength:     options.MaxLength,
+		RequireUpper:  options.RequireUpper,
+		RequireLower:  options.RequireLower,
+		RequireDigit:  options.RequireDigit,
+		RequireSymbol: options.RequireSymbol,
+		MinClasses:    options.MinClasses,
+	}}
+
+	// an uncompiled dictionary is converted into a hash set, do it once
+	// and only if needed
+	words := sync.OnceValue(func() *wordSet {
+		return options.Dictionary.wordSet()
+	})
+
+	if options.Passphrase {
+		checks = append(checks, PassphraseCheck{
+			Dictionary: options.Dictionary,
+			Words:      options.PassphraseWords,
+			WordLength: options.PassphraseWordLength,
+			Entropy:    options.PassphraseEntropy,
+			words:      words,
+		})
+	}
+
+	if options.Entropy > 0 {
+		checks = append(checks, EntropyCheck{Min: options.Entropy})
+	}
+
+	if options.TotalEntropy > 0 {
+		checks = append(checks, TotalEntropyCheck{Min: options.TotalEntropy})
+	}
+
+	if options.PoolEntropy > 0 {
+		checks = append(checks, PoolEntropyCheck{Min: options.PoolEntropy})
+	}
+
+	if options.Compress > 0 {
+		checks = append(checks, CompressCheck{Max: options.Compress, Compressors: options.Compressors})
+	}
+
+	if options.Explained > 0 {
+		checks = append(checks, ExplainedCheck{Max: options.Explained, Dictionary: options.Dictionary,
+			UserInputs: options.UserInputs})
+	}
+
+	if options.CharDistribution > 0 {
+		checks = append(checks, DistributionCheck{Min: options.CharDistribution, AlphabetSize: options.AlphabetSize})
+	}
+
+	if options.Dictionary != nil {
+		checks = append(checks, DictionaryCheck{Dictionary: options.Dictionary})
+	}
+
+	if len(options.UserInputs) > 0 {
+		checks = append(checks, UserInputCheck{UserInputs: options.UserInputs, Dictionary: options.Dictionary})
+	}
+
+	if options.KeyboardWalk > 0 {
+		checks = append(checks, KeyboardCheck{Min: options.KeyboardWalk, Layouts: options.KeyboardLayouts})
+	}
+
+	if options.Sequence > 0 {
+		checks = append(checks, SequenceCheck{Min: options.Sequence})
+	}
+
+	if options.Dates {
+		checks = append(checks, DateCheck{MinYear: options.MinYear, MaxYear: options.MaxYear})
+	}
+
+	if options.GuessesLog10 > 0 {
+		checks = append(checks, GuessesCheck{
+			Min:             options.GuessesLog10,
+			Dictionary:      options.Dictionary,
+			UserInputs:      options.UserInputs,
+			KeyboardLayouts: options.KeyboardLayouts,
+			MinYear:         options.MinYear,
+			MaxYear:         options.MaxYear,
+			dicts:           getGuessDicts(options.Dictionary, options.UserInputs, words),
+		})
+	}
+
+	if options.History != nil {
+		checks = append(checks, HistoryCheck{History: options.History})
+	}
+
+	checkers := []BreachChecker{}
+	if options.BreachFile != "" {
+		checkers = append(checkers, breachFile(options.BreachFile))
+	}
+
+	if options.Breach != nil {
+		checkers = append(checkers, options.Breach)
+	}
+
+	if len(checkers) > 0 {
+		checks = append(checks, BreachCheck{Checkers: checkers, MinCount: options.BreachMinCount})
+	}
+
+	return checks
+}
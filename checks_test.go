@@ -0,0 +1,235 @@
+package valpass_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tlinden/valpass"
+)
+
+// a company specific rule
+type companyCheck struct {
+	name string
+}
+
+func (check companyCheck) Name() string {
+	return "company"
+}
+
+func (check companyCheck) Run(passphrase string, result *valpass.Result) error {
+	count := strings.Count(strings.ToLower(passphrase), check.name)
+
+	result.SetMetric(check.Name(), float64(count))
+
+	if count > 0 {
+		result.Fail(check.Name(), 0, float64(count), "password contains the company name")
+	}
+
+	return nil
+}
+
+type lengthCheck struct{}
+
+func (lengthCheck) Name() string {
+	return "length"
+}
+
+func (lengthCheck) Run(passphrase string, result *valpass.Result) error {
+	result.SetMetric("length", float64(utf8.RuneCountInString(passphrase)))
+	return nil
+}
+
+type brokenCheck struct{}
+
+func (brokenCheck) Name() string {
+	return "broken"
+}
+
+func (brokenCheck) Run(string, *valpass.Result) error {
+	return errors.New("broken check")
+}
+
+func TestChecks(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.Options{
+		Entropy: valpass.MIN_ENTROPY,
+		Checks:  []valpass.Check{companyCheck{name: "acme"}, lengthCheck{}},
+	}
+
+	result, err := valpass.Validate(`xACMEx`, opts)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Ok || len(result.Failures) != 2 ||
+		result.Failures[0].Check != valpass.CHECK_ENTROPY || result.Failures[1].Check != "company" {
+		t.Errorf("unexpected failures: %v", result.Failures)
+	}
+
+	if result.Metrics["company"] != 1 || result.Metrics["length"] != 6 ||
+		result.Metrics[valpass.CHECK_ENTROPY] != result.Entropy {
+		t.Errorf("unexpected metrics: %v", result.Metrics)
+	}
+
+	// built-in checks can be used as custom checks as well
+	for _, pass := range append(pass_random_good, pass_worst_bad...) {
+		want, err := valpass.Validate(pass, opts_std)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		got, err := valpass.Validate(pass, valpass.Options{Checks: []valpass.Check{
+			valpass.EntropyCheck{Min: opts_std.Entropy},
+			valpass.CompressCheck{Max: opts_std.Compress},
+			valpass.DistributionCheck{Min: opts_std.CharDistribution},
+		}})
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if got.Ok != want.Ok || len(got.Failures) != len(want.Failures) || got.Score != want.Score ||
+			math.Abs(got.Entropy-want.Entropy) > 1e-9 {
+			t.Errorf("built-in checks differ for %s. want: %v, got: %v", pass, want, got)
+		}
+	}
+
+	// failed custom checks cap the score like the built-in ones
+	result, err = valpass.Validate(`oIsCdyd7rP8oNmH29y8OmBAcmeAqKAFHHWr87v`, valpass.Options{
+		TotalEntropy: 1,
+		Checks:       []valpass.Check{companyCheck{name: "acme"}},
+	})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Ok || result.Score != valpass.DEFAULT_SCORE_CUTOFFS.Custom {
+		t.Errorf("failed custom check did not cap the score: %v", result)
+	}
+
+	opts.Checks = append(opts.Checks, brokenCheck{})
+	if _, err := valpass.Validate(`xACMEx`, opts); err == nil {
+		t.Errorf("check errors are not returned")
+	}
+
+	if _, err := valpass.NewValidator(valpass.Options{Checks: []valpass.Check{nil}}); err == nil {
+		t.Errorf("nil check not detected")
+	}
+}
+
+func TestZeroChecks(t *testing.T) {
+	t.Parallel()
+
+	// zero values use the defaults, they must neither panic nor fail
+	// every password
+	for _, check := range []valpass.Check{
+		valpass.PolicyCheck{},
+		valpass.EntropyCheck{},
+		valpass.TotalEntropyCheck{},
+		valpass.PoolEntropyCheck{},
+		valpass.CompressCheck{},
+		valpass.ExplainedCheck{},
+		valpass.DistributionCheck{},
+		valpass.UserInputCheck{},
+		valpass.KeyboardCheck{},
+		valpass.SequenceCheck{},
+		valpass.DateCheck{},
+		valpass.GuessesCheck{},
+		valpass.BreachCheck{},
+	} {
+		result, err := valpass.Validate(pass_random_good[0], valpass.Options{Checks: []valpass.Check{check}})
+		if err != nil {
+			t.Fatalf("zero %s check failed with error: %s", check.Name(), err)
+		}
+
+		if !result.Ok {
+			t.Errorf("zero %s check rejected a random password: %v", check.Name(), result.Failures)
+		}
+	}
+
+	// checks which can't work without their input return an error
+	for _, check := range []valpass.Check{
+		valpass.PassphraseCheck{},
+		valpass.DictionaryCheck{},
+		valpass.HistoryCheck{},
+	} {
+		if _, err := valpass.Validate(pass_random_good[0], valpass.Options{Checks: []valpass.Check{check}}); err == nil {
+			t.Errorf("zero %s check did not return an error", check.Name())
+		}
+	}
+}
+
+func TestBuiltinChecks(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.Options{
+		Entropy:      valpass.MIN_ENTROPY,
+		Sequence:     valpass.MIN_SEQUENCE,
+		KeyboardWalk: valpass.MIN_KEYBOARD_WALK,
+		Checks:       []valpass.Check{lengthCheck{}},
+	}
+
+	names := []string{}
+	for _, check := range valpass.BuiltinChecks(opts) {
+		names = append(names, check.Name())
+	}
+
+	want := []string{valpass.CHECK_POLICY, valpass.CHECK_ENTROPY, valpass.CHECK_KEYBOARD, valpass.CHECK_SEQUENCE}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected built-in checks. want: %v, got: %v", want, names)
+	}
+
+	// reorder the built-in checks and replace the entropy check
+	opts.Builtin = []valpass.Check{
+		valpass.SequenceCheck{Min: valpass.MIN_SEQUENCE},
+		companyCheck{name: "acme"},
+		valpass.PolicyCheck{},
+	}
+
+	result, err := valpass.Validate(`abcdefACME`, opts)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if len(result.Failures) != 2 || result.Failures[0].Check != valpass.CHECK_SEQUENCE ||
+		result.Failures[1].Check != "company" {
+		t.Errorf("unexpected failures: %v", result.Failures)
+	}
+
+	if _, ok := result.Metrics[valpass.CHECK_ENTROPY]; ok || result.Metrics["length"] != 10 {
+		t.Errorf("unexpected metrics: %v", result.Metrics)
+	}
+
+	// the score doesn't depend on the PolicyCheck
+	result, err = valpass.Validate(pass_random_good[0], valpass.Options{
+		Builtin: []valpass.Check{valpass.EntropyCheck{Min: valpass.MIN_ENTROPY}},
+	})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !result.Ok || result.Length != 32 || result.Score != valpass.MAX_SCORE {
+		t.Errorf("unexpected result without policy check: %v", result)
+	}
+
+	// passphrases skip the per char checks without relying on names
+	passphrase := `correct-horse-battery-staple`
+	result, err = valpass.Validate(passphrase, valpass.Options{Checks: []valpass.Check{
+		valpass.PassphraseCheck{Dictionary: dict_compiled},
+		valpass.EntropyCheck{Min: 4},
+	}})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !result.Ok || !result.Passphrase || result.Entropy != 0 {
+		t.Errorf("per char check applied to passphrase: %v", result)
+	}
+
+	if _, err := valpass.NewValidator(valpass.Options{Builtin: []valpass.Check{nil}}); err == nil {
+		t.Errorf("nil built-in check not detected")
+	}
+}
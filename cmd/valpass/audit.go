@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tlinden/valpass"
+)
+
+// width of the longest histogram bar
+const histogram_width int = 40
+
+// Run the audit subcommand, the passwords are read from the file given
+// on the command line or stdin.
+func runAudit(conf *Config, validator *valpass.Validator, stdin io.Reader, stdout, stderr io.Writer) int {
+	input := stdin
+
+	if conf.auditfile != "" && conf.auditfile != "-" {
+		file, err := os.Open(conf.auditfile)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		defer file.Close()
+		input = file
+	}
+
+	options := valpass.AuditOptions{
+		Workers:      conf.workers,
+		ReportFormat: conf.reportformat,
+		Top:          conf.top,
+	}
+
+	if conf.report != "" {
+		report, err := os.OpenFile(conf.report, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		defer report.Close()
+		options.Report = report
+	}
+
+	stats, err := validator.Audit(input, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_ERROR
+	}
+
+	if conf.json {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(stats); err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		return EXIT_OK
+	}
+
+	printStats(stdout, stats)
+
+	return EXIT_OK
+}
+
+// Print the statistics as text.
+func printStats(stdout io.Writer, stats *valpass.AuditStats) {
+	percent := func(count int) float64 {
+		if stats.Total == 0 {
+			return 0
+		}
+
+		return float64(count) * 100 / float64(stats.Total)
+	}
+
+	fmt.Fprintf(stdout, "Passwords: %10d\n", stats.Total)
+	fmt.Fprintf(stdout, "Passed:    %10d  %6.2f%%\n", stats.Passed, percent(stats.Passed))
+	fmt.Fprintf(stdout, "Failed:    %10d  %6.2f%%\n", stats.Failed, percent(stats.Failed))
+	fmt.Fprintf(stdout, "Errors:    %10d  %6.2f%%\n", stats.Errors, percent(stats.Errors))
+
+	checks := make([]string, 0, len(stats.Checks))
+	for check := range stats.Checks {
+		checks = append(checks, check)
+	}
+
+	sort.Strings(checks)
+
+	fmt.Fprintln(stdout, "\nFailed checks:")
+	for _, check := range checks {
+		fmt.Fprintf(stdout, "  %-12s %10d  %6.2f%%\n", check, stats.Checks[check].Failed, stats.Checks[check].Rate)
+	}
+
+	fmt.Fprintln(stdout, "\nScores:")
+	for score, count := range stats.Scores {
+		fmt.Fprintf(stdout, "  %d %-12s %8d\n", score, valpass.SCORE_LABELS[score], count)
+	}
+
+	printHistogram(stdout, "Entropy (bits/char)", stats.Entropy)
+	printHistogram(stdout, "Compression (%)", stats.Compress)
+	printHistogram(stdout, "Character distribution (%)", stats.CharDistribution)
+
+	printCounts(stdout, "Top dictionary hits", stats.DictionaryHits)
+	printCounts(stdout, "Top failure reasons", stats.FailureReasons)
+}
+
+func printHistogram(stdout io.Writer, title string, histogram valpass.Histogram) {
+	if len(histogram.Buckets) == 0 {
+		return
+	}
+
+	fmt.Fprintf(stdout, "\n%s:\n", title)
+
+	highest := 1
+	for _, count := range histogram.Buckets {
+		highest = max(highest, count)
+	}
+
+	for bucket, count := range histogram.Buckets {
+		from := float64(bucket) * histogram.Width
+		bar := strings.Repeat("#", count*histogram_width/highest)
+
+		fmt.Fprintf(stdout, "  %6.1f - %6.1f %8d %s\n", from, from+histogram.Width, count, bar)
+	}
+}
+
+func printCounts(stdout io.Writer, title string, counts []valpass.Count) {
+	if len(counts) == 0 {
+		return
+	}
+
+	fmt.Fprintf(stdout, "\n%s:\n", title)
+
+	for _, count := range counts {
+		fmt.Fprintf(stdout, "  %-30s %8d\n", count.Name, count.Count)
+	}
+}
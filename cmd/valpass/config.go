@@ -0,0 +1,292 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tlinden/valpass"
+)
+
+// Config contains the parsed command line.
+type Config struct {
+	options valpass.Options
+
+	dictpath string
+	submatch bool
+	fuzzy    bool
+	leet     bool
+
+	breachapi bool
+
+	historypath string
+	historysize int
+	historyadd  bool
+
+	layouts     string
+	compressors string
+	userinputs  stringList
+
+	json    bool
+	quiet   bool
+	version bool
+
+	// audit subcommand
+	audit        bool
+	auditfile    string
+	workers      int
+	report       string
+	reportformat string
+	top          int
+
+	// generate subcommand
+	generate bool
+	genopts  valpass.GeneratorOptions
+	count    int
+}
+
+// stringList is a flag which can be given multiple times.
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+const usage string = `Usage: valpass [options] < passwords
+       valpass [options]           (prompts for the password)
+       valpass audit [options] [file]
+       valpass generate [options]
+
+Options:
+%s
+Exit codes:
+  0   all passwords are valid
+  1   runtime error, e.g. unreadable dictionary
+  2   usage error
+  10+ password failed, the code tells the first failed check:
+%s`
+
+const auditusage string = `Usage: valpass audit [options] [file]
+
+Validates every line of the file (or stdin) and prints statistics.
+
+Options:
+%s`
+
+const generateusage string = `Usage: valpass generate [options]
+
+Prints random passwords which pass the validation, one per line. The
+theoretical entropy goes to stderr.
+
+Options:
+%s`
+
+// Parse the command line into a Config.
+func parseFlags(args []string, stderr io.Writer) (*Config, error) {
+	conf := &Config{options: valpass.DefaultOptions()}
+	opts := &conf.options
+
+	name := "valpass"
+	if len(args) > 0 {
+		switch args[0] {
+		case "audit":
+			conf.audit = true
+		case "generate":
+			conf.generate = true
+		}
+
+		if conf.audit || conf.generate {
+			name = "valpass " + args[0]
+			args = args[1:]
+		}
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.IntVar(&opts.Compress, "compress", opts.Compress, "minimum compression rate in percent, 0 to disable")
+	flags.StringVar(&conf.compressors, "compressors", "",
+		"comma separated compressors for -compress, default flate, available: "+
+			strings.Join(valpass.CompressorNames(), ","))
+	flags.Float64Var(&opts.CharDistribution, "dist", opts.CharDistribution,
+		"minimum character distribution in percent, 0 to disable")
+	flags.Float64Var(&opts.Entropy, "entropy", opts.Entropy, "minimum entropy in bits/char, 0 to disable")
+	flags.Float64Var(&opts.TotalEntropy, "total-entropy", 0, "minimum entropy of the whole password in bits")
+	flags.Float64Var(&opts.PoolEntropy, "pool-entropy", 0,
+		"minimum entropy in bits estimated from length and character classes")
+	flags.IntVar(&opts.AlphabetSize, "alphabet", 0, "number of possible chars for the distribution, default 95")
+
+	flags.StringVar(&conf.dictpath, "dict", "", "dictionary file, one word per line")
+	flags.IntVar(&opts.Explained, "explained", 0,
+		fmt.Sprintf("flag passwords explained by dictionary words and user inputs by this many percent, e.g. %d",
+			valpass.MAX_EXPLAINED))
+	flags.BoolVar(&conf.submatch, "dict-submatch", false, "flag passwords which are part of a dictionary word")
+	flags.BoolVar(&conf.fuzzy, "dict-fuzzy", false, "flag passwords similar to a dictionary word")
+	flags.BoolVar(&conf.leet, "dict-leet", false, "undo l33t substitutions before dictionary lookups")
+
+	flags.StringVar(&opts.BreachFile, "breach-file", "", "sorted HIBP \"SHA1:count\" file")
+	flags.BoolVar(&conf.breachapi, "breach-api", false, "lookup passwords using the HIBP range API")
+	flags.IntVar(&opts.BreachMinCount, "breach-min", 0, "minimum breach count to flag a password")
+
+	flags.IntVar(&opts.MinLength, "min-length", 0, "minimum password length")
+	flags.IntVar(&opts.MaxLength, "max-length", 0, "maximum password length")
+	flags.BoolVar(&opts.RequireUpper, "upper", false, "require an upper case letter")
+	flags.BoolVar(&opts.RequireLower, "lower", false, "require a lower case letter")
+	flags.BoolVar(&opts.RequireDigit, "digit", false, "require a digit")
+	flags.BoolVar(&opts.RequireSymbol, "symbol", false, "require a special character")
+	flags.IntVar(&opts.MinClasses, "min-classes", 0, "minimum number of character classes, 1-4")
+
+	flags.IntVar(&opts.KeyboardWalk, "keyboard", 0,
+		fmt.Sprintf("flag keyboard walks of at least this many keys, e.g. %d", valpass.MIN_KEYBOARD_WALK))
+	flags.StringVar(&conf.layouts, "layouts", "",
+		"comma separated keyboard layouts, default: "+strings.Join(valpass.KeyboardLayouts(), ","))
+	flags.IntVar(&opts.Sequence, "sequence", 0,
+		fmt.Sprintf("flag sequences and repetitions of at least this many chars, e.g. %d", valpass.MIN_SEQUENCE))
+	flags.BoolVar(&opts.Dates, "dates", false, "flag dates and years")
+	flags.IntVar(&opts.MinYear, "min-year", 0, "years before this are not considered dates")
+	flags.IntVar(&opts.MaxYear, "max-year", 0, "years after this are not considered dates")
+	flags.Float64Var(&opts.GuessesLog10, "guesses", 0,
+		fmt.Sprintf("minimum number of guesses as log10, e.g. %.0f", valpass.MIN_GUESSES_LOG10))
+
+	flags.BoolVar(&opts.Passphrase, "passphrase", false, "check passphrases by word instead of by char, requires -dict")
+	flags.IntVar(&opts.PassphraseWords, "passphrase-words", 0,
+		fmt.Sprintf("minimum number of words of a passphrase, default %d", valpass.PASSPHRASE_WORDS))
+	flags.IntVar(&opts.PassphraseWordLength, "passphrase-word-length", 0,
+		fmt.Sprintf("minimum length of every word of a passphrase, default %d", valpass.PASSPHRASE_WORD_LENGTH))
+	flags.Float64Var(&opts.PassphraseEntropy, "passphrase-entropy", 0,
+		fmt.Sprintf("minimum entropy of a passphrase in bits, default %.0f", valpass.PASSPHRASE_ENTROPY))
+
+	flags.Var(&conf.userinputs, "user-input", "user name, email etc. the password must not be based on, repeatable")
+
+	switch {
+	case conf.audit:
+		flags.IntVar(&conf.workers, "workers", 0, "number of passwords validated in parallel, default number of CPUs")
+		flags.StringVar(&conf.report, "report", "", "write a per-line report to this file, it contains neither passwords nor dictionary words")
+		flags.StringVar(&conf.reportformat, "report-format", valpass.AUDIT_CSV, "report format, csv or json")
+		flags.IntVar(&conf.top, "top", valpass.AUDIT_TOP, "number of top dictionary hits and failure reasons")
+		flags.BoolVar(&conf.json, "json", false, "print the statistics as JSON")
+	case conf.generate:
+		gen := &conf.genopts
+		flags.IntVar(&gen.Length, "length", valpass.GENERATOR_LENGTH, "password length")
+		flags.IntVar(&conf.count, "count", 1, "number of passwords to generate")
+		flags.StringVar(&gen.Alphabet, "chars", valpass.ALPHABET_PRINTABLE, "characters to choose from")
+		flags.StringVar(&gen.Forbidden, "forbid", "", "characters which must not be used")
+		flags.BoolVar(&gen.StartWithLetter, "start-letter", false, "start with a letter")
+		flags.BoolVar(&conf.quiet, "q", false, "don't print the entropy")
+	default:
+		flags.StringVar(&conf.historypath, "history", "", "password history file")
+		flags.IntVar(&conf.historysize, "history-size", valpass.HISTORY_SIZE, "number of previous passwords to keep")
+		flags.BoolVar(&conf.historyadd, "history-add", false, "add valid passwords to the history")
+
+		flags.BoolVar(&conf.json, "json", false, "print the results as JSON, one object per line")
+		flags.BoolVar(&conf.quiet, "q", false, "print nothing, just set the exit code")
+	}
+
+	flags.BoolVar(&conf.version, "version", false, "print the version")
+
+	flags.Usage = func() {
+		var defaults strings.Builder
+
+		flags.SetOutput(&defaults)
+		flags.PrintDefaults()
+		flags.SetOutput(stderr)
+
+		switch {
+		case conf.audit:
+			fmt.Fprintf(stderr, auditusage, defaults.String())
+		case conf.generate:
+			fmt.Fprintf(stderr, generateusage, defaults.String())
+		default:
+			fmt.Fprintf(stderr, usage, defaults.String(), exitCodeUsage())
+		}
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if conf.audit && flags.NArg() <= 1 {
+		conf.auditfile = flags.Arg(0)
+		return conf, nil
+	}
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+		// don't print the arguments, they might contain a password
+		return nil, fmt.Errorf("unexpected arguments, passwords are read from stdin")
+	}
+
+	if conf.historyadd && conf.historypath == "" {
+		return nil, fmt.Errorf("-history-add requires -history")
+	}
+
+	return conf, nil
+}
+
+// Returns the validator and the history store (if any) for the config.
+func (conf *Config) validator() (*valpass.Validator, valpass.HistoryStore, error) {
+	opts, history, err := conf.validationOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	validator, err := valpass.NewValidator(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return validator, history, nil
+}
+
+// Returns the validation options and the history store (if any) for
+// the config.
+func (conf *Config) validationOptions() (valpass.Options, valpass.HistoryStore, error) {
+	opts := conf.options
+
+	if conf.dictpath != "" {
+		dict, err := valpass.LoadDictionary(conf.dictpath)
+		if err != nil {
+			return opts, nil, err
+		}
+
+		dict.Submatch = conf.submatch
+		dict.Fuzzy = conf.fuzzy
+		dict.Leet = conf.leet
+		opts.Dictionary = dict
+	}
+
+	if conf.breachapi {
+		opts.Breach = valpass.NewRangeClient()
+	}
+
+	if conf.layouts != "" {
+		opts.KeyboardLayouts = strings.Split(conf.layouts, ",")
+	}
+
+	if conf.compressors != "" {
+		for _, name := range strings.Split(conf.compressors, ",") {
+			compressor, err := valpass.NewCompressor(name)
+			if err != nil {
+				return opts, nil, err
+			}
+
+			opts.Compressors = append(opts.Compressors, compressor)
+		}
+	}
+
+	opts.UserInputs = conf.userinputs
+
+	var history valpass.HistoryStore
+	if conf.historypath != "" {
+		history = valpass.NewFileHistory(conf.historypath, conf.historysize)
+		opts.History = history
+	}
+
+	return opts, history, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tlinden/valpass"
+)
+
+// Run the generate subcommand, the passwords are validated using the
+// same options as the passwords read from stdin.
+func runGenerate(conf *Config, stdout, stderr io.Writer) int {
+	opts, _, err := conf.validationOptions()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_ERROR
+	}
+
+	generator, err := valpass.NewGenerator(conf.genopts, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_ERROR
+	}
+
+	for i := 0; i < conf.count; i++ {
+		password, err := generator.Generate()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		fmt.Fprintln(stdout, password)
+	}
+
+	if !conf.quiet {
+		fmt.Fprintf(stderr, "entropy: %.2f bits\n", generator.Entropy())
+	}
+
+	return EXIT_OK
+}
@@ -0,0 +1,22 @@
+/*
+valpass validates passwords from the command line.
+
+Passwords are never accepted as arguments, because these are visible
+in the process list. If stdin is a terminal, valpass prompts for the
+password with echo turned off, otherwise it reads one password per
+line from stdin.
+
+The exit code tells the first reason why a password failed, see
+usage.
+*/
+package main
+
+import (
+	"os"
+)
+
+const VERSION string = "0.1.0"
+
+func main() {
+	os.Exit(Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
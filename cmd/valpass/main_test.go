@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pass_good string = `oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`
+
+func run(t *testing.T, stdin string, args ...string) (int, string) {
+	var stdout, stderr bytes.Buffer
+
+	code := Main(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String() + stderr.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	t.Parallel()
+
+	history := filepath.Join(t.TempDir(), "history")
+
+	var clitests = []struct {
+		name  string
+		stdin string
+		args  []string
+		code  int
+	}{
+		{"good", pass_good + "\n", nil, EXIT_OK},
+		{"entropy", "aaaaaaaaaaaa\n", nil, 10},
+		{"first-failure", pass_good + "\naaaaaaaaaaaa\n", nil, 10},
+		{"pool-entropy", "x7Q!\n", []string{"-entropy", "0", "-dist", "0", "-pool-entropy", "60"}, 10},
+		{"compressors", "aaaaaaaaaaaa\n", []string{"-entropy", "0", "-dist", "0", "-compressors", "flate,rle"}, 11},
+		{"explained", "JohnDoe!Summer\n", []string{"-explained", "50", "-user-input", "john.doe@example.com",
+			"-dict", "../../t/american-english"}, 11},
+		{"unknown-compressor", "", []string{"-compressors", "bzip2"}, EXIT_ERROR},
+		{"policy", pass_good, []string{"-min-length", "50"}, 15},
+		{"dictionary", "clock\n", []string{"-entropy", "0", "-compress", "0", "-dist", "0",
+			"-dict", "../../t/american-english"}, 13},
+		{"keyboard", "qwertzuiop\n", []string{"-entropy", "0", "-dist", "0", "-keyboard", "4"}, 16},
+		{"passphrase", "correct horse battery\n", []string{"-dict", "../../t/american-english", "-passphrase"}, 22},
+		{"history-add", pass_good + "\n", []string{"-history", history, "-history-add"}, EXIT_OK},
+		{"history", pass_good + "\n", []string{"-history", history}, 21},
+		{"arguments", "", []string{pass_good}, EXIT_USAGE},
+		{"unknown-flag", "", []string{"-nonexistent"}, EXIT_USAGE},
+		{"invalid-options", "", []string{"-compress", "200"}, EXIT_ERROR},
+		{"missing-dict", "", []string{"-dict", "nonexistent"}, EXIT_ERROR},
+		{"help", "", []string{"-h"}, EXIT_OK},
+	}
+
+	// the history tests depend on each other
+	for _, tt := range clitests {
+		code, output := run(t, tt.stdin, tt.args...)
+		if code != tt.code {
+			t.Errorf("%s: unexpected exit code. want: %d, got: %d\n%s", tt.name, tt.code, code, output)
+		}
+
+		if strings.Contains(output, pass_good) {
+			t.Errorf("%s: output contains the password", tt.name)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+
+	code, output := run(t, "aaaaaaaaaaaa\n"+pass_good+"\n", "-json")
+	if code != 10 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(output))
+
+	for line := 1; line <= 2; line++ {
+		var result struct {
+			Line     int
+			Ok       bool
+			Failures []struct{ Check string }
+		}
+
+		if err := decoder.Decode(&result); err != nil {
+			t.Fatalf("invalid JSON output: %s\n%s", err, output)
+		}
+
+		if result.Line != line || result.Ok != (line == 2) {
+			t.Errorf("unexpected result in line %d: %v", line, result)
+		}
+	}
+
+	code, output = run(t, "aaaaaaaaaaaa\n", "-q")
+	if code != 10 || output != "" {
+		t.Errorf("quiet mode is not quiet: %d %q", code, output)
+	}
+
+	_, output = run(t, "aaaaaaaaaaaa\n")
+	if !strings.Contains(output, "1: invalid") || !strings.Contains(output, "failed:      password entropy") {
+		t.Errorf("unexpected text output: %s", output)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	t.Parallel()
+
+	report := filepath.Join(t.TempDir(), "report.json")
+	dump := "clock\n" + pass_good + "\naaaaaaaaaaaa\nclock\n"
+
+	code, output := run(t, dump, "audit", "-dict", "../../t/american-english", "-report", report,
+		"-report-format", "json", "-workers", "2")
+	if code != EXIT_OK {
+		t.Fatalf("audit failed: %d\n%s", code, output)
+	}
+
+	for _, want := range []string{"Passwords:          4", "Passed:             1", "dictionary", "clock"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("audit output lacks %q:\n%s", want, output)
+		}
+	}
+
+	content, err := os.ReadFile(report)
+	if err != nil || strings.Count(string(content), "\n") != 4 || strings.Contains(string(content), pass_good) {
+		t.Errorf("unexpected report: %v\n%s", err, content)
+	}
+
+	code, output = run(t, dump, "audit", "-json")
+	if code != EXIT_OK || !strings.Contains(output, `"Total": 4`) {
+		t.Errorf("unexpected JSON statistics: %d\n%s", code, output)
+	}
+
+	if code, _ := run(t, "", "audit", "nonexistent"); code != EXIT_ERROR {
+		t.Errorf("missing audit file not detected: %d", code)
+	}
+
+	if code, _ := run(t, "", "audit", "a", "b"); code != EXIT_USAGE {
+		t.Errorf("too many audit files not detected: %d", code)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr bytes.Buffer
+
+	code := Main([]string{"generate", "-count", "3", "-length", "16", "-forbid", "0O1l", "-min-classes", "4"},
+		strings.NewReader(""), &stdout, &stderr)
+	if code != EXIT_OK {
+		t.Fatalf("generate failed: %d\n%s", code, stderr.String())
+	}
+
+	passwords := strings.Fields(stdout.String())
+	if len(passwords) != 3 {
+		t.Fatalf("expected 3 passwords, got: %q", passwords)
+	}
+
+	for _, password := range passwords {
+		if len(password) != 16 || strings.ContainsAny(password, "0O1l") {
+			t.Errorf("unexpected password %q", password)
+		}
+
+		if code, output := run(t, password, "-min-classes", "4"); code != EXIT_OK {
+			t.Errorf("generated password doesn't pass: %d\n%s", code, output)
+		}
+	}
+
+	if !strings.Contains(stderr.String(), "entropy:") {
+		t.Errorf("entropy missing: %s", stderr.String())
+	}
+
+	if code, _ := run(t, "", "generate", "-chars", "abc", "-digit"); code != EXIT_ERROR {
+		t.Errorf("impossible constraints not detected: %d", code)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// Read a single line byte by byte, so that nothing after it is being
+// consumed.
+func readLine(reader io.Reader) (string, error) {
+	var line strings.Builder
+	char := make([]byte, 1)
+
+	for {
+		n, err := reader.Read(char)
+		if n == 1 {
+			if char[0] == '\n' {
+				break
+			}
+
+			line.WriteByte(char[0])
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimRight(line.String(), "\r"), nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "syscall"
+
+const (
+	ioctl_get_termios = syscall.TCGETS
+	ioctl_set_termios = syscall.TCSETS
+)
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// We don't know how to turn off echo here, so passwords are always
+// read from stdin as lines.
+func isTerminal(file *os.File) bool {
+	return false
+}
+
+func readPassword(file *os.File) (string, error) {
+	return "", errors.New("reading passwords from a terminal is not supported on this platform")
+}
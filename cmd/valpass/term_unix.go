@@ -0,0 +1,73 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+func getTermios(file *os.File) (*syscall.Termios, error) {
+	termios := &syscall.Termios{}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), ioctl_get_termios, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	return termios, nil
+}
+
+func setTermios(file *os.File, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), ioctl_set_termios, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Returns true if the file is a terminal.
+func isTerminal(file *os.File) bool {
+	_, err := getTermios(file)
+	return err == nil
+}
+
+// Read a line from the terminal with echo turned off. The terminal is
+// restored, even if we get interrupted.
+func readPassword(file *os.File) (string, error) {
+	orig, err := getTermios(file)
+	if err != nil {
+		return "", err
+	}
+
+	noecho := *orig
+	noecho.Lflag &^= syscall.ECHO
+	noecho.Lflag |= syscall.ICANON | syscall.ISIG
+
+	if err := setTermios(file, &noecho); err != nil {
+		return "", err
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-interrupts:
+			_ = setTermios(file, orig)
+			os.Exit(EXIT_ERROR)
+		case <-done:
+		}
+	}()
+
+	defer signal.Stop(interrupts)
+	defer func() { _ = setTermios(file, orig) }()
+
+	return readLine(file)
+}
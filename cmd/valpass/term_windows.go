@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const enable_echo_input uint32 = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// Returns true if the file is a console.
+func isTerminal(file *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(file.Fd()), &mode) == nil
+}
+
+// Read a line from the console with echo turned off.
+func readPassword(file *os.File) (string, error) {
+	handle := syscall.Handle(file.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return "", err
+	}
+
+	if ok, _, err := setConsoleMode.Call(uintptr(handle), uintptr(mode&^enable_echo_input)); ok == 0 {
+		return "", err
+	}
+
+	defer setConsoleMode.Call(uintptr(handle), uintptr(mode))
+
+	return readLine(file)
+}
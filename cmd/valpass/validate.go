@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tlinden/valpass"
+)
+
+const (
+	EXIT_OK     int = 0
+	EXIT_ERROR  int = 1
+	EXIT_USAGE  int = 2
+	EXIT_FAILED int = 40 // failed a check without its own exit code, e.g. a custom one
+)
+
+// exit codes of failed checks, the policy checks share one
+var exitcodes = []struct {
+	check string
+	code  int
+}{
+	{valpass.CHECK_ENTROPY, 10},
+	{valpass.CHECK_TOTAL, 10},
+	{valpass.CHECK_POOL, 10},
+	{valpass.CHECK_COMPRESS, 11},
+	{valpass.CHECK_EXPLAINED, 11},
+	{valpass.CHECK_CHARDIST, 12},
+	{valpass.CHECK_DICTIONARY, 13},
+	{valpass.CHECK_BREACH, 14},
+	{valpass.CHECK_MINLENGTH, 15},
+	{valpass.CHECK_MAXLENGTH, 15},
+	{valpass.CHECK_UPPER, 15},
+	{valpass.CHECK_LOWER, 15},
+	{valpass.CHECK_DIGIT, 15},
+	{valpass.CHECK_SYMBOL, 15},
+	{valpass.CHECK_CLASSES, 15},
+	{valpass.CHECK_KEYBOARD, 16},
+	{valpass.CHECK_SEQUENCE, 17},
+	{valpass.CHECK_DATE, 18},
+	{valpass.CHECK_GUESSES, 19},
+	{valpass.CHECK_USERINPUT, 20},
+	{valpass.CHECK_HISTORY, 21},
+	{valpass.CHECK_PASSPHRASE, 22},
+	{valpass.CHECK_WORDS, 22},
+	{valpass.CHECK_WORDLENGTH, 22},
+	{valpass.CHECK_REPEATED, 22},
+}
+
+// Returns the exit code of a failed check.
+func exitCode(check string) int {
+	for _, entry := range exitcodes {
+		if entry.check == check {
+			return entry.code
+		}
+	}
+
+	return EXIT_FAILED
+}
+
+// Returns the exit codes of the checks for the usage.
+func exitCodeUsage() string {
+	checks := map[int][]string{}
+	codes := []int{}
+
+	for _, entry := range exitcodes {
+		if _, ok := checks[entry.code]; !ok {
+			codes = append(codes, entry.code)
+		}
+
+		checks[entry.code] = append(checks[entry.code], entry.check)
+	}
+
+	sort.Ints(codes)
+
+	var text strings.Builder
+	for _, code := range codes {
+		fmt.Fprintf(&text, "      %d  %s\n", code, strings.Join(checks[code], ", "))
+	}
+
+	fmt.Fprintf(&text, "      %d  any other check\n", EXIT_FAILED)
+
+	return text.String()
+}
+
+/*
+Main runs valpass with the given arguments and returns the exit code.
+If stdin is a terminal, it prompts for a single password, otherwise
+every line is a password. The exit code is the one of the first
+password which failed.
+*/
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	conf, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return EXIT_OK
+		}
+
+		fmt.Fprintf(stderr, "error: %s\n", err)
+
+		return EXIT_USAGE
+	}
+
+	if conf.version {
+		fmt.Fprintf(stdout, "valpass version %s\n", VERSION)
+		return EXIT_OK
+	}
+
+	if conf.generate {
+		return runGenerate(conf, stdout, stderr)
+	}
+
+	validator, history, err := conf.validator()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_ERROR
+	}
+
+	if conf.audit {
+		return runAudit(conf, validator, stdin, stdout, stderr)
+	}
+
+	passwords, err := readPasswords(stdin, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s\n", err)
+		return EXIT_ERROR
+	}
+
+	code := EXIT_OK
+
+	for line := 1; ; line++ {
+		pass, ok, err := passwords()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		if !ok {
+			break
+		}
+
+		result, err := validator.Validate(pass)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: line %d: %s\n", line, err)
+			return EXIT_ERROR
+		}
+
+		if result.Ok && conf.historyadd {
+			if err := history.Add(pass); err != nil {
+				fmt.Fprintf(stderr, "error: %s\n", err)
+				return EXIT_ERROR
+			}
+		}
+
+		if err := conf.print(stdout, line, result); err != nil {
+			fmt.Fprintf(stderr, "error: %s\n", err)
+			return EXIT_ERROR
+		}
+
+		if !result.Ok && code == EXIT_OK {
+			code = exitCode(result.Failures[0].Check)
+		}
+	}
+
+	return code
+}
+
+/*
+Returns a function which returns the next password, false if there are
+no more. A terminal gets a prompt with echo turned off, otherwise we
+read lines.
+*/
+func readPasswords(stdin io.Reader, stderr io.Writer) (func() (string, bool, error), error) {
+	if file, ok := stdin.(*os.File); ok && isTerminal(file) {
+		fmt.Fprint(stderr, "Password: ")
+		pass, err := readPassword(file)
+		fmt.Fprintln(stderr)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read password: %w", err)
+		}
+
+		done := false
+
+		return func() (string, bool, error) {
+			if done {
+				return "", false, nil
+			}
+
+			done = true
+
+			return pass, true, nil
+		}, nil
+	}
+
+	scanner := bufio.NewScanner(stdin)
+
+	return func() (string, bool, error) {
+		if !scanner.Scan() {
+			return "", false, scanner.Err()
+		}
+
+		return strings.TrimRight(scanner.Text(), "\r"), true, nil
+	}, nil
+}
+
+// Print the result of a password, never the password itself.
+func (conf *Config) print(stdout io.Writer, line int, result valpass.Result) error {
+	switch {
+	case conf.quiet:
+		return nil
+	case conf.json:
+		return json.NewEncoder(stdout).Encode(struct {
+			Line int `json:"line"`
+			valpass.Result
+		}{line, result})
+	}
+
+	status := "valid"
+	if !result.Ok {
+		status = "invalid"
+	}
+
+	fmt.Fprintf(stdout, "%d: %s", line, status)
+	if result.ScoreLabel != "" {
+		fmt.Fprintf(stdout, ", score %d/%d (%s)", result.Score, valpass.MAX_SCORE, result.ScoreLabel)
+	}
+
+	fmt.Fprintln(stdout)
+
+	metrics := make([]string, 0, len(result.Metrics))
+	for name := range result.Metrics {
+		metrics = append(metrics, name)
+	}
+
+	sort.Strings(metrics)
+
+	for _, name := range metrics {
+		fmt.Fprintf(stdout, "   %-12s %.2f\n", name+":", result.Metrics[name])
+	}
+
+	if result.GuessesLog10 > 0 {
+		fmt.Fprintf(stdout, "   %-12s 10^%.2f\n", "guesses:", result.GuessesLog10)
+	}
+
+	for _, failure := range result.Failures {
+		fmt.Fprintf(stdout, "   failed:      %s\n", failure.Message)
+	}
+
+	return nil
+}
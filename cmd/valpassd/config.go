@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tlinden/valpass"
+)
+
+const (
+	DEFAULT_LISTEN       string = "localhost:8080"
+	DEFAULT_MAX_BODY     int64  = 16 * 1024
+	DEFAULT_MAX_PASSWORD int    = 256
+	DEFAULT_MAX_INPUTS   int    = 8
+	DEFAULT_MAX_INPUT    int    = 64
+)
+
+/*
+Config is the content of the config file, e.g.:
+
+	{
+	  "listen": ":8080",
+	  "options": {"MinLength": 12, "KeyboardWalk": 4, "GuessesLog10": 10},
+	  "dictionary": {"path": "/usr/share/dict/words", "leet": true},
+	  "compressors": ["flate", "rle"],
+	  "breach_file": "/var/lib/hibp/pwned-passwords-sha1-ordered-by-hash.txt"
+	}
+
+The options are  applied on top of valpass.DefaultOptions(), field
+names are the ones of valpass.Options. Dictionary, compressors, breach
+checkers, history and custom checks can't be configured there.
+*/
+type Config struct {
+	Listen      string           `json:"listen"`
+	MaxBody     int64            `json:"max_body"`        // maximum request size in bytes
+	MaxPassword int              `json:"max_password"`    // maximum password length in characters
+	MaxInputs   int              `json:"max_user_inputs"` // maximum number of user inputs per request
+	MaxInput    int              `json:"max_user_input"`  // maximum user input length in characters
+	Options     valpass.Options  `json:"options"`
+	Dictionary  DictionaryConfig `json:"dictionary"`
+	Compressors []string         `json:"compressors"` // names of the compressors, see valpass.CompressorNames()
+	BreachFile  string           `json:"breach_file"`
+	BreachAPI   bool             `json:"breach_api"` // use the HIBP range API
+}
+
+// DictionaryConfig configures the dictionary lookups.
+type DictionaryConfig struct {
+	Path     string `json:"path"` // word list, one word per line
+	Submatch bool   `json:"submatch"`
+	Fuzzy    bool   `json:"fuzzy"`
+	Leet     bool   `json:"leet"`
+}
+
+// LoadConfig reads the config file, an empty path returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	conf := Config{
+		Listen:      DEFAULT_LISTEN,
+		MaxBody:     DEFAULT_MAX_BODY,
+		MaxPassword: DEFAULT_MAX_PASSWORD,
+		MaxInputs:   DEFAULT_MAX_INPUTS,
+		MaxInput:    DEFAULT_MAX_INPUT,
+		Options:     valpass.DefaultOptions(),
+	}
+
+	if path == "" {
+		return conf, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return conf, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&conf); err != nil {
+		return conf, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return conf, nil
+}
+
+// Returns the options including dictionary and breach checkers.
+func (conf Config) options() (valpass.Options, error) {
+	opts := conf.Options
+
+	if conf.Dictionary.Path != "" {
+		dict, err := valpass.LoadDictionary(conf.Dictionary.Path)
+		if err != nil {
+			return opts, err
+		}
+
+		dict.Submatch = conf.Dictionary.Submatch
+		dict.Fuzzy = conf.Dictionary.Fuzzy
+		dict.Leet = conf.Dictionary.Leet
+		opts.Dictionary = dict
+	}
+
+	for _, name := range conf.Compressors {
+		compressor, err := valpass.NewCompressor(name)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Compressors = append(opts.Compressors, compressor)
+	}
+
+	if conf.BreachFile != "" {
+		opts.BreachFile = conf.BreachFile
+	}
+
+	if conf.BreachAPI {
+		opts.Breach = valpass.NewRangeClient()
+	}
+
+	return opts, nil
+}
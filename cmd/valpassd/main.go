@@ -0,0 +1,83 @@
+/*
+valpassd is a small HTTP service which validates passwords, so that
+services not written in Go can share the same password policy.
+
+Endpoints:
+
+	POST /validate  {"password": "...", "user_inputs": ["..."]} => valpass.Result
+	GET  /policy    the configured options
+	GET  /health    {"status": "ok"}
+
+The options and the dictionary are being loaded from a JSON config file
+at startup, see Config. Submitted passwords are never logged.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	VERSION string = "0.1.0"
+
+	shutdown_timeout time.Duration = 10 * time.Second
+)
+
+func main() {
+	configfile := flag.String("config", "", "config file, JSON")
+	listen := flag.String("listen", "", "listen address, overrides the config file")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "valpassd: ", log.LstdFlags)
+
+	conf, err := LoadConfig(*configfile)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	if *listen != "" {
+		conf.Listen = *listen
+	}
+
+	handler, err := NewServer(conf, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	server := &http.Server{
+		Addr:              conf.Listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+
+		timeout, cancel := context.WithTimeout(context.Background(), shutdown_timeout)
+		defer cancel()
+
+		if err := server.Shutdown(timeout); err != nil {
+			logger.Printf("shutdown failed: %s", err)
+		}
+	}()
+
+	logger.Printf("version %s listening on %s", VERSION, conf.Listen)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+}
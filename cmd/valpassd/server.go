@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tlinden/valpass"
+)
+
+// Server handles the HTTP requests.
+type Server struct {
+	validator *valpass.Validator
+	policy    Policy
+	maxbody   int64
+	maxpass   int
+	maxinputs int
+	maxinput  int
+	logger    *log.Logger
+	mux       *http.ServeMux
+}
+
+// Request is the body of POST /validate.
+type Request struct {
+	Password   string   `json:"password"`
+	UserInputs []string `json:"user_inputs"` // user name, email etc., see valpass.Options.UserInputs
+}
+
+// Policy is the response of GET /policy, the options without the
+// dictionary words and other non-serializable parts.
+type Policy struct {
+	Options     valpass.Options   `json:"options"`
+	Dictionary  *DictionaryPolicy `json:"dictionary"`
+	Compressors []string          `json:"compressors"`
+	BreachAPI   bool              `json:"breach_api"`
+}
+
+// DictionaryPolicy describes the configured dictionary.
+type DictionaryPolicy struct {
+	Words    int  `json:"words"`
+	Submatch bool `json:"submatch"`
+	Fuzzy    bool `json:"fuzzy"`
+	Leet     bool `json:"leet"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer loads the dictionary, creates the validator and returns
+// the handler of all endpoints.
+func NewServer(conf Config, logger *log.Logger) (*Server, error) {
+	opts, err := conf.options()
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := valpass.NewValidator(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		validator: validator,
+		policy:    newPolicy(opts),
+		maxbody:   conf.MaxBody,
+		maxpass:   conf.MaxPassword,
+		maxinputs: conf.MaxInputs,
+		maxinput:  conf.MaxInput,
+		logger:    logger,
+		mux:       http.NewServeMux(),
+	}
+
+	if server.maxbody <= 0 {
+		server.maxbody = DEFAULT_MAX_BODY
+	}
+
+	if server.maxpass <= 0 {
+		server.maxpass = DEFAULT_MAX_PASSWORD
+	}
+
+	if server.maxinputs <= 0 {
+		server.maxinputs = DEFAULT_MAX_INPUTS
+	}
+
+	if server.maxinput <= 0 {
+		server.maxinput = DEFAULT_MAX_INPUT
+	}
+
+	server.mux.HandleFunc("POST /validate", server.validate)
+	server.mux.HandleFunc("GET /policy", server.getPolicy)
+	server.mux.HandleFunc("GET /health", server.health)
+
+	return server, nil
+}
+
+func newPolicy(opts valpass.Options) Policy {
+	policy := Policy{Options: opts, BreachAPI: opts.Breach != nil}
+
+	if dict := opts.Dictionary; dict != nil {
+		policy.Dictionary = &DictionaryPolicy{
+			Words:    len(dict.Words),
+			Submatch: dict.Submatch,
+			Fuzzy:    dict.Fuzzy,
+			Leet:     dict.Leet,
+		}
+	}
+
+	for _, compressor := range opts.Compressors {
+		policy.Compressors = append(policy.Compressors, compressor.Name())
+	}
+
+	policy.Options.Dictionary = nil
+	policy.Options.Compressors = nil
+	policy.Options.Breach = nil
+	policy.Options.History = nil
+	policy.Options.Checks = nil
+	policy.Options.Builtin = nil
+
+	return policy
+}
+
+// ServeHTTP logs every request, but never its body.
+func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+
+	server.mux.ServeHTTP(recorder, request)
+
+	server.logger.Printf("%s %s %d %s", request.Method, request.URL.Path, recorder.status, time.Since(start))
+}
+
+func (server *Server) validate(writer http.ResponseWriter, request *http.Request) {
+	var body Request
+
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, server.maxbody))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&body); err != nil {
+		var toolarge *http.MaxBytesError
+		if errors.As(err, &toolarge) {
+			respond(writer, http.StatusRequestEntityTooLarge, errorResponse{Error: "request too large"})
+			return
+		}
+
+		// the decoder error might quote parts of the password
+		respond(writer, http.StatusBadRequest, errorResponse{Error: "invalid request, expected JSON"})
+		return
+	}
+
+	// some checks get expensive with the length, reject long passwords
+	// before validating them
+	if utf8.RuneCountInString(body.Password) > server.maxpass {
+		respond(writer, http.StatusBadRequest,
+			errorResponse{Error: fmt.Sprintf("password too long, at most %d characters", server.maxpass)})
+		return
+	}
+
+	// every user input is looked up in the password, partly fuzzy, so
+	// they are limited even more
+	if len(body.UserInputs) > server.maxinputs {
+		respond(writer, http.StatusBadRequest,
+			errorResponse{Error: fmt.Sprintf("too many user inputs, at most %d", server.maxinputs)})
+		return
+	}
+
+	for _, input := range body.UserInputs {
+		if utf8.RuneCountInString(input) > server.maxinput {
+			respond(writer, http.StatusBadRequest,
+				errorResponse{Error: fmt.Sprintf("user input too long, at most %d characters", server.maxinput)})
+			return
+		}
+	}
+
+	validator := server.validator
+
+	if len(body.UserInputs) > 0 {
+		// the options are shared by all requests, never append to
+		// their slices in place
+		opts := validator.Options()
+		opts.UserInputs = append(slices.Clip(opts.UserInputs), body.UserInputs...)
+
+		// the dictionary is already compiled, this is cheap
+		var err error
+		if validator, err = valpass.NewValidator(opts); err != nil {
+			respond(writer, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	result, err := validator.Validate(body.Password)
+	if errors.Is(err, valpass.ErrNonPrintable) {
+		// don't log the error, it contains the offending character
+		respond(writer, http.StatusBadRequest, errorResponse{Error: valpass.ErrNonPrintable.Error()})
+		return
+	}
+
+	if err != nil {
+		server.logger.Printf("validation failed: %s", err)
+		respond(writer, http.StatusInternalServerError, errorResponse{Error: "validation failed"})
+
+		return
+	}
+
+	respond(writer, http.StatusOK, result)
+}
+
+func (server *Server) getPolicy(writer http.ResponseWriter, _ *http.Request) {
+	respond(writer, http.StatusOK, server.policy)
+}
+
+func (server *Server) health(writer http.ResponseWriter, _ *http.Request) {
+	respond(writer, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func respond(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	_ = json.NewEncoder(writer).Encode(body)
+}
+
+// statusRecorder remembers the status code for logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+const (
+	pass_good string = `oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`
+	pass_bad  string = `S3cur1ty`
+)
+
+func NewTestServer(t *testing.T, config string) (*httptest.Server, *bytes.Buffer) {
+	path := filepath.Join(t.TempDir(), "valpassd.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+
+	var logs bytes.Buffer
+
+	server, err := NewServer(conf, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("failed to create server: %s", err)
+	}
+
+	testserver := httptest.NewServer(server)
+	t.Cleanup(testserver.Close)
+
+	return testserver, &logs
+}
+
+func Post(t *testing.T, url, body string, response any) int {
+	resp, err := http.Post(url+"/validate", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		t.Fatalf("invalid response: %s", err)
+	}
+
+	return resp.StatusCode
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	server, logs := NewTestServer(t, `{
+	  "max_body": 256,
+	  "options": {"MinLength": 12},
+	  "dictionary": {"path": "../../t/american-english", "leet": true}
+	}`)
+
+	var result valpass.Result
+
+	if status := Post(t, server.URL, `{"password": "`+pass_good+`"}`, &result); status != http.StatusOK || !result.Ok {
+		t.Errorf("good password rejected: %d %v", status, result)
+	}
+
+	if status := Post(t, server.URL, `{"password": "`+pass_bad+`"}`, &result); status != http.StatusOK || result.Ok ||
+		!result.DictionaryMatch || result.Failures[0].Check != valpass.CHECK_MINLENGTH {
+		t.Errorf("bad password accepted: %d %v", status, result)
+	}
+
+	body := `{"password": "Acme` + pass_good + `", "user_inputs": ["Acme Corp"]}`
+	if status := Post(t, server.URL, body, &result); status != http.StatusOK || result.Ok {
+		t.Errorf("user inputs ignored: %d %v", status, result)
+	}
+
+	var failure errorResponse
+
+	var errortests = []struct {
+		body   string
+		status int
+	}{
+		{`{"password": "` + pass_bad, http.StatusBadRequest},
+		{`{"passwort": "` + pass_bad + `"}`, http.StatusBadRequest},
+		{`{"password": "` + pass_bad + `\u0007"}`, http.StatusBadRequest},
+		{`{"password": "` + strings.Repeat(pass_bad, 100) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range errortests {
+		if status := Post(t, server.URL, tt.body, &failure); status != tt.status || failure.Error == "" {
+			t.Errorf("unexpected response to %s: %d %v", tt.body, status, failure)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/validate")
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /validate not rejected: %d", resp.StatusCode)
+	}
+
+	if strings.Contains(logs.String(), pass_good) || strings.Contains(logs.String(), "S3cur") {
+		t.Errorf("password has been logged:\n%s", logs.String())
+	}
+
+	if !strings.Contains(logs.String(), "POST /validate 200") {
+		t.Errorf("requests are not logged:\n%s", logs.String())
+	}
+}
+
+func TestMaxPassword(t *testing.T) {
+	t.Parallel()
+
+	server, _ := NewTestServer(t, `{"max_password": 40}`)
+
+	var result valpass.Result
+
+	if status := Post(t, server.URL, `{"password": "`+pass_good+`"}`, &result); status != http.StatusOK {
+		t.Errorf("password within the limit rejected: %d %v", status, result)
+	}
+
+	var failure errorResponse
+
+	if status := Post(t, server.URL, `{"password": "x`+pass_good+`"}`, &failure); status != http.StatusBadRequest ||
+		failure.Error == "" {
+		t.Errorf("too long password accepted: %d %v", status, failure)
+	}
+}
+
+func TestMaxUserInputs(t *testing.T) {
+	t.Parallel()
+
+	server, _ := NewTestServer(t, `{"max_user_input": 40, "max_user_inputs": 2}`)
+
+	var result valpass.Result
+
+	if status := Post(t, server.URL, `{"password": "`+pass_good+`", "user_inputs": ["jdoe", "acme"]}`,
+		&result); status != http.StatusOK {
+		t.Errorf("user inputs within the limits rejected: %d %v", status, result)
+	}
+
+	for _, inputs := range []string{
+		`["jdoe", "acme", "example"]`,
+		`["` + strings.Repeat("x", 41) + `"]`,
+	} {
+		var failure errorResponse
+
+		if status := Post(t, server.URL, `{"password": "`+pass_good+`", "user_inputs": `+inputs+`}`,
+			&failure); status != http.StatusBadRequest || failure.Error == "" {
+			t.Errorf("user inputs %s accepted: %d %v", inputs, status, failure)
+		}
+	}
+}
+
+// A request at the limits must not keep the server busy, the user
+// inputs cost about as much as the password itself. Allocations are
+// counted instead of timing it, therefore the test does not run in
+// parallel.
+func TestLimits(t *testing.T) {
+	server, _ := NewTestServer(t, `{"options": {"GuessesLog10": 10, "Explained": 50},
+	  "dictionary": {"path": "../../t/american-english", "fuzzy": true, "leet": true}}`)
+
+	password := strings.Repeat(`p4$$w0rd!Xk9#mQ2`, DEFAULT_MAX_PASSWORD/16)
+
+	inputs := make([]string, DEFAULT_MAX_INPUTS)
+	for i := range inputs {
+		words := make([]string, DEFAULT_MAX_INPUT/8+1)
+		for j := range words {
+			words[j] = fmt.Sprintf("%c%06d", 'a'+j, i)
+		}
+
+		inputs[i] = fmt.Sprintf("%q", strings.Join(words, " ")[:DEFAULT_MAX_INPUT])
+	}
+
+	post := func(body string) func() {
+		return func() {
+			var result valpass.Result
+
+			if status := Post(t, server.URL, body, &result); status != http.StatusOK {
+				t.Fatalf("request at the limits rejected: %d %v", status, result)
+			}
+		}
+	}
+
+	want := testing.AllocsPerRun(1, post(`{"password": "`+password+`"}`)) * 4
+	got := testing.AllocsPerRun(1,
+		post(`{"password": "`+password+`", "user_inputs": [`+strings.Join(inputs, ",")+`]}`))
+
+	if got > want {
+		t.Errorf("user inputs at the limits need too much work: %.0f allocations, want at most %.0f", got, want)
+	}
+}
+
+func TestConcurrentUserInputs(t *testing.T) {
+	t.Parallel()
+
+	// enough configured inputs to leave spare capacity in the shared
+	// slice, run with -race
+	inputs := []string{}
+	for i := 0; i < 17; i++ {
+		inputs = append(inputs, fmt.Sprintf("%q", fmt.Sprintf("configured%c", 'a'+i)))
+	}
+
+	server, _ := NewTestServer(t, `{"options": {"UserInputs": [`+strings.Join(inputs, ",")+`]}}`)
+
+	words := []string{"walnut", "pelican", "orchid", "granite", "saffron", "tundra", "lantern", "quiver"}
+
+	var wait sync.WaitGroup
+
+	for round := 0; round < 10; round++ {
+		for i, word := range words {
+			wait.Add(1)
+
+			// the password contains the input of the next request,
+			// which must not leak into this one
+			go func(input, next string) {
+				defer wait.Done()
+
+				var result valpass.Result
+
+				body := fmt.Sprintf(`{"password": "%s%s", "user_inputs": ["%s"]}`, pass_good, next, input)
+				if status := Post(t, server.URL, body, &result); status != http.StatusOK ||
+					len(result.UserInputMatches) != 0 {
+					t.Errorf("user inputs leaked between requests: %d %v", status, result.UserInputMatches)
+				}
+			}(word, words[(i+1)%len(words)])
+		}
+	}
+
+	wait.Wait()
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	server, _ := NewTestServer(t, `{"options": {"MinLength": 12, "Entropy": 0},
+	  "dictionary": {"path": "../../t/american-english", "fuzzy": true}, "compressors": ["flate", "rle"]}`)
+
+	for _, endpoint := range []string{"/policy", "/health"} {
+		resp, err := http.Get(server.URL + endpoint)
+		if err != nil {
+			t.Fatalf("request failed: %s", err)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("invalid response: %s", err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s failed: %d", endpoint, resp.StatusCode)
+		}
+
+		switch endpoint {
+		case "/health":
+			if body["status"] != "ok" {
+				t.Errorf("unexpected health: %v", body)
+			}
+		case "/policy":
+			options := body["options"].(map[string]any)
+			dict := body["dictionary"].(map[string]any)
+
+			if options["MinLength"] != 12.0 || options["Entropy"] != 0.0 ||
+				options["Compress"] != float64(valpass.MIN_COMPRESS) || options["Dictionary"] != nil ||
+				dict["words"] != 104332.0 || dict["fuzzy"] != true ||
+				fmt.Sprint(body["compressors"]) != "[flate rle]" {
+				t.Errorf("unexpected policy: %v", body)
+			}
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	conf, err := LoadConfig("")
+	if err != nil || conf.Listen != DEFAULT_LISTEN || conf.Options.Entropy != valpass.MIN_ENTROPY {
+		t.Errorf("unexpected default config: %v %v", conf, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "valpassd.json")
+
+	for _, config := range []string{`{"listen": 8080}`, `{"unknown": true}`, `{"options": {"Compress": 500}}`,
+		`{"dictionary": {"path": "nonexistent"}}`, `{"compressors": ["bzip2"]}`} {
+		if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+			t.Fatalf("failed to write config: %s", err)
+		}
+
+		conf, err := LoadConfig(path)
+		if err == nil {
+			_, err = NewServer(conf, log.New(&bytes.Buffer{}, "", 0))
+		}
+
+		if err == nil {
+			t.Errorf("invalid config not detected: %s", config)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package valpass
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/lzw"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	COMPRESSOR_FLATE string = "flate"
+	COMPRESSOR_ZLIB  string = "zlib"
+	COMPRESSOR_GZIP  string = "gzip"
+	COMPRESSOR_LZW   string = "lzw"
+	COMPRESSOR_RLE   string = "rle"
+
+	MAX_EXPLAINED int = 50 // e.g. for Options.Explained
+
+	// dictionary words sharing fragments of this many bytes with the
+	// password are put into the preset
+	explained_fragment int = 4
+
+	// the window of flate, a larger preset would be ignored anyway
+	explained_max_preset int = 32 * 1024
+
+	// bytes added by the container formats around the deflate stream
+	zlib_overhead int = 2 + 4  // header, adler32
+	gzip_overhead int = 10 + 8 // minimal header, crc32 and size
+)
+
+/*
+Compressor can be implemented to add a compression backend to the
+compression metric, see Options.Compressors. Name returns a stable
+identifier, which is being used as the key in Result.Compressions.
+CompressedSize returns the size of the compressed data in bytes,
+without headers and checksums of the container format, they would
+dominate the size of a password. Compressors must be safe for
+concurrent use.
+*/
+type Compressor interface {
+	Name() string
+	CompressedSize(data []byte) (int, error)
+}
+
+// FlateCompressor uses compress/flate with Level 1-9, 9 if zero. This
+// is the default compressor.
+type FlateCompressor struct {
+	Level int
+}
+
+func (compressor FlateCompressor) Name() string {
+	return COMPRESSOR_FLATE
+}
+
+func (compressor FlateCompressor) CompressedSize(data []byte) (int, error) {
+	var buffer bytes.Buffer
+
+	writer, err := flate.NewWriter(&buffer, compressionLevel(compressor.Level))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create flate writer: %w", err)
+	}
+
+	return deflateSize(writer, &buffer, data, 0)
+}
+
+// ZlibCompressor uses compress/zlib with Level 1-9, 9 if zero.
+type ZlibCompressor struct {
+	Level int
+}
+
+func (compressor ZlibCompressor) Name() string {
+	return COMPRESSOR_ZLIB
+}
+
+func (compressor ZlibCompressor) CompressedSize(data []byte) (int, error) {
+	var buffer bytes.Buffer
+
+	writer, err := zlib.NewWriterLevel(&buffer, compressionLevel(compressor.Level))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create zlib writer: %w", err)
+	}
+
+	return deflateSize(writer, &buffer, data, zlib_overhead)
+}
+
+// GzipCompressor uses compress/gzip with Level 1-9, 9 if zero.
+type GzipCompressor struct {
+	Level int
+}
+
+func (compressor GzipCompressor) Name() string {
+	return COMPRESSOR_GZIP
+}
+
+func (compressor GzipCompressor) CompressedSize(data []byte) (int, error) {
+	var buffer bytes.Buffer
+
+	writer, err := gzip.NewWriterLevel(&buffer, compressionLevel(compressor.Level))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+
+	return deflateSize(writer, &buffer, data, gzip_overhead)
+}
+
+// LZWCompressor uses compress/lzw with LSB order and 8 bit literals, as
+// used by GIF.
+type LZWCompressor struct{}
+
+func (compressor LZWCompressor) Name() string {
+	return COMPRESSOR_LZW
+}
+
+func (compressor LZWCompressor) CompressedSize(data []byte) (int, error) {
+	var buffer bytes.Buffer
+
+	writer := lzw.NewWriter(&buffer, lzw.LSB, 8)
+
+	if _, err := writer.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write to lzw writer: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close lzw writer: %w", err)
+	}
+
+	return buffer.Len(), nil
+}
+
+/*
+RLECompressor is a plain run-length encoder: every run of up to 255
+equal bytes is encoded as a count and the byte. It only finds
+repeated characters, but these reliably.
+*/
+type RLECompressor struct{}
+
+func (compressor RLECompressor) Name() string {
+	return COMPRESSOR_RLE
+}
+
+func (compressor RLECompressor) CompressedSize(data []byte) (int, error) {
+	size := 0
+
+	for pos := 0; pos < len(data); {
+		run := 1
+		for pos+run < len(data) && data[pos+run] == data[pos] && run < 255 {
+			run++
+		}
+
+		size += 2
+		pos += run
+	}
+
+	return size, nil
+}
+
+// CompressorNames returns the names of the built-in compressors.
+func CompressorNames() []string {
+	return []string{COMPRESSOR_FLATE, COMPRESSOR_ZLIB, COMPRESSOR_GZIP, COMPRESSOR_LZW, COMPRESSOR_RLE}
+}
+
+// NewCompressor returns the built-in compressor with the given name,
+// using its default settings.
+func NewCompressor(name string) (Compressor, error) {
+	switch name {
+	case COMPRESSOR_FLATE:
+		return FlateCompressor{}, nil
+	case COMPRESSOR_ZLIB:
+		return ZlibCompressor{}, nil
+	case COMPRESSOR_GZIP:
+		return GzipCompressor{}, nil
+	case COMPRESSOR_LZW:
+		return LZWCompressor{}, nil
+	case COMPRESSOR_RLE:
+		return RLECompressor{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown compressor: %s", name)
+}
+
+// Returns the compression level, zero means the best compression.
+func compressionLevel(level int) int {
+	if level == 0 {
+		return flate.BestCompression
+	}
+
+	return level
+}
+
+// a deflate based writer
+type deflateWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Compress the data and return the size without the container overhead.
+func deflateSize(writer deflateWriter, buffer *bytes.Buffer, data []byte, overhead int) (int, error) {
+	if _, err := writer.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write to compressor: %w", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush compressor: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close compressor: %w", err)
+	}
+
+	return buffer.Len() - overhead, nil
+}
+
+/*
+Return the share of the password in percent which is explained by known
+material: the lower cased password is compressed with flate twice, once
+as is and once primed with a preset made of dictionary words and the
+user input tokens. The more the preset shrinks the compressed size
+(without the constant overhead of flate), the more of the password
+consists of known material. Case is ignored, just like dictionary
+lookups do.
+*/
+func getExplained(passphrase string, dict *Dictionary, inputs []string) (int, error) {
+	lcpass := strings.ToLower(passphrase)
+
+	preset := getPreset(lcpass, dict, inputs)
+	if len(preset) == 0 {
+		return 0, nil
+	}
+
+	plain, err := primedSize([]byte(lcpass), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	primed, err := primedSize([]byte(lcpass), preset)
+	if err != nil {
+		return 0, err
+	}
+
+	// the size of an empty stream is overhead, not password
+	empty, err := primedSize(nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if plain <= empty || primed >= plain {
+		return 0, nil
+	}
+
+	return Min((plain-primed)*100/(plain-empty), 100), nil
+}
+
+/*
+Build the preset from whole dictionary words and user input tokens.
+A dictionary doesn't fit into the 32 KB window of flate, therefore only
+words which share at least one fragment of explained_fragment bytes
+with the password are being used, e.g. "horses" and "batter" for
+"correcthorsebatterystaple". Flate favours recent bytes, so the most
+relevant material comes last: words mostly made of fragments of the
+password after those only sharing some, the user input tokens at the
+very end. The least relevant words are dropped if the preset gets too
+large.
+*/
+func getPreset(lcpass string, dict *Dictionary, inputs []string) []byte {
+	tokens := []byte{}
+	for _, token := range getUserTokens(inputs) {
+		tokens = append(tokens, token.token...)
+	}
+
+	words := []explainedWord{}
+	if dict != nil {
+		words = getExplainedWords(lcpass, dict)
+	}
+
+	// most relevant last, keep the dictionary order otherwise
+	sort.SliceStable(words, func(i, j int) bool {
+		if words[i].coverage != words[j].coverage {
+			return words[i].coverage < words[j].coverage
+		}
+
+		return words[i].shared < words[j].shared
+	})
+
+	budget := explained_max_preset - len(tokens)
+	size := 0
+	first := len(words)
+
+	for first > 0 && size+len(words[first-1].word) <= budget {
+		first--
+		size += len(words[first].word)
+	}
+
+	preset := make([]byte, 0, size+len(tokens))
+	for _, word := range words[first:] {
+		preset = append(preset, word.word...)
+	}
+
+	return append(preset, tokens...)
+}
+
+// explainedWord is a dictionary word related to the password.
+type explainedWord struct {
+	word     string  // lower cased
+	shared   int     // number of fragments shared with the password
+	coverage float64 // share of the fragments of the word found in the password
+}
+
+/*
+Returns the dictionary words which contain fragments of the password.
+Compiled dictionaries look the fragments up in their suffix array,
+uncompiled ones are scanned completely. Fragments are compared as
+bytes, flate doesn't know about runes either.
+*/
+func getExplainedWords(lcpass string, dict *Dictionary) []explainedWord {
+	fragments := map[string]bool{}
+
+	for start := 0; start+explained_fragment <= len(lcpass); start++ {
+		fragments[lcpass[start:start+explained_fragment]] = true
+	}
+
+	if len(fragments) == 0 {
+		return nil
+	}
+
+	shared := map[int]int{} // word id => shared fragments
+	lower := func(id int) string { return strings.ToLower(dict.Words[id]) }
+
+	if index := dict.index; index != nil {
+		lower = func(id int) string { return index.lower[id] }
+
+		for fragment := range fragments {
+			for _, offset := range index.subs.Lookup([]byte(fragment), -1) {
+				// the word starting at or before the offset
+				shared[sort.SearchInts(index.starts, offset+1)-1]++
+			}
+		}
+	} else {
+		for id, word := range dict.Words {
+			word = strings.ToLower(word)
+
+			for start := 0; start+explained_fragment <= len(word); start++ {
+				if fragments[word[start:start+explained_fragment]] {
+					shared[id]++
+				}
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(shared))
+	for id := range shared {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	words := make([]explainedWord, 0, len(ids))
+	seen := map[string]bool{}
+
+	for _, id := range ids {
+		if word := lower(id); !seen[word] {
+			seen[word] = true
+			words = append(words, explainedWord{
+				word:     word,
+				shared:   shared[id],
+				coverage: float64(shared[id]) / float64(len(word)-explained_fragment+1),
+			})
+		}
+	}
+
+	return words
+}
+
+// Returns the size of the data compressed by flate, primed with the
+// preset dictionary if not nil.
+func primedSize(data, preset []byte) (int, error) {
+	var buffer bytes.Buffer
+
+	writer, err := flate.NewWriterDict(&buffer, flate.BestCompression, preset)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create flate writer: %w", err)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write to flate writer: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close flate writer: %w", err)
+	}
+
+	return buffer.Len(), nil
+}
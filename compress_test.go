@@ -0,0 +1,160 @@
+package valpass_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+func getCompressors(t *testing.T, names ...string) []valpass.Compressor {
+	compressors := []valpass.Compressor{}
+
+	for _, name := range names {
+		compressor, err := valpass.NewCompressor(name)
+		if err != nil {
+			t.Fatalf("failed to get compressor: %s", err)
+		}
+
+		compressors = append(compressors, compressor)
+	}
+
+	return compressors
+}
+
+func TestCompressors(t *testing.T) {
+	t.Parallel()
+
+	var compresstests = []struct {
+		pass  string
+		rates map[string]int
+	}{
+		{`aaaaaaaaaaaa`, map[string]int{"flate": 8, "zlib": 8, "gzip": 8, "lzw": 33, "rle": 83}},
+		{`abcabcabcabcabcabc`, map[string]int{"flate": 27, "zlib": 27, "gzip": 27, "lzw": 27, "rle": 0}},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, map[string]int{"flate": 0, "zlib": 0, "gzip": 0, "lzw": 0, "rle": 0}},
+	}
+
+	opts := valpass.Options{Compress: valpass.MIN_COMPRESS,
+		Compressors: getCompressors(t, valpass.CompressorNames()...)}
+
+	for _, tt := range compresstests {
+		result, err := valpass.Validate(tt.pass, opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		highest := 0
+
+		for name, want := range tt.rates {
+			if got := result.Compressions[name]; got != want {
+				t.Errorf("unexpected %s compression of %s. want: %d, got: %d", name, tt.pass, want, got)
+			}
+
+			highest = valpass.Max(highest, want)
+		}
+
+		if result.Compress != highest {
+			t.Errorf("unexpected overall compression of %s. want: %d, got: %d", tt.pass, highest, result.Compress)
+		}
+	}
+
+	// the default is flate only
+	result, err := valpass.Validate(`aaaaaaaaaaaa`, valpass.Options{Compress: valpass.MIN_COMPRESS})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if len(result.Compressions) != 1 || result.Compressions[valpass.COMPRESSOR_FLATE] != 8 || !result.Ok {
+		t.Errorf("unexpected default compression: %v", result.Compressions)
+	}
+
+	// rle catches what flate misses
+	CheckPassword(t, `aaaaaaaaaaaa`, Test{name: "checkbad-rle", want: false,
+		opts: valpass.Options{Compress: valpass.MIN_COMPRESS, Compressors: getCompressors(t, "rle")}})
+}
+
+func TestCompressorOptions(t *testing.T) {
+	t.Parallel()
+
+	if _, err := valpass.NewCompressor("nonexistent"); err == nil {
+		t.Errorf("unknown compressor not detected")
+	}
+
+	for name, compressors := range map[string][]valpass.Compressor{
+		"nil":       {nil},
+		"duplicate": {valpass.FlateCompressor{}, valpass.FlateCompressor{Level: 5}},
+		"flate":     {valpass.FlateCompressor{Level: 42}},
+		"zlib":      {valpass.ZlibCompressor{Level: -1}},
+		"gzip":      {valpass.GzipCompressor{Level: 10}},
+	} {
+		if _, err := valpass.NewValidator(valpass.Options{Compress: 10, Compressors: compressors}); !errors.Is(err, valpass.ErrInvalidOptions) {
+			t.Errorf("%s: invalid compressors not detected: %v", name, err)
+		}
+	}
+
+	if _, err := valpass.Validate(`aaaaaaaaaaaa`, valpass.Options{Compress: 10,
+		Compressors: []valpass.Compressor{valpass.FlateCompressor{Level: 42}}}); !errors.Is(err, valpass.ErrInvalidOptions) {
+		t.Errorf("invalid compression level not detected: %v", err)
+	}
+
+	if _, err := valpass.NewValidator(valpass.Options{Compress: 10, Compressors: []valpass.Compressor{
+		valpass.FlateCompressor{}, valpass.ZlibCompressor{Level: 1}, valpass.GzipCompressor{Level: 9}}}); err != nil {
+		t.Errorf("valid compression levels rejected: %s", err)
+	}
+}
+
+func TestExplained(t *testing.T) {
+	t.Parallel()
+
+	var explainedtests = []struct {
+		pass string
+		min  int // explained at least
+		max  int // explained at most
+	}{
+		{`correcthorsebatterystaple`, 60, 100},
+		{`CorrectHorseBatteryStaple`, 60, 100},
+		{`JohnDoe!Summer`, 50, 100},
+		// no dictionary words, but parts of them
+		{`xorrectxorsexattery`, 30, 100},
+		{`johndoe`, 50, 100},
+		{`Tr0ub4dor&3`, 0, 0},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, 0, 0},
+	}
+
+	opts := valpass.Options{
+		Explained:  valpass.MAX_EXPLAINED,
+		Dictionary: opts_dict.Dictionary,
+		UserInputs: []string{"john.doe@example.com"},
+	}
+
+	for _, tt := range explainedtests {
+		result, err := valpass.Validate(tt.pass, opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Explained < tt.min || result.Explained > tt.max {
+			t.Errorf("unexpected explained share of %s. want: %d-%d%%, got: %d%%",
+				tt.pass, tt.min, tt.max, result.Explained)
+		}
+
+		if result.Ok != (result.Explained < valpass.MAX_EXPLAINED) {
+			t.Errorf("%s: explained %d%%, but ok is %t", tt.pass, result.Explained, result.Ok)
+		}
+	}
+
+	for _, pass := range pass_random_good {
+		CheckPassword(t, pass, Test{name: "checkgood-explained", want: true, opts: opts})
+	}
+
+	// user inputs alone
+	result, err := valpass.Validate(`johndoe`, valpass.Options{Explained: valpass.MAX_EXPLAINED,
+		UserInputs: []string{"John Doe"}})
+	if err != nil || result.Ok {
+		t.Errorf("user input not explained: %v %d%%", err, result.Explained)
+	}
+
+	if _, err := valpass.NewValidator(valpass.Options{Explained: 101}); !errors.Is(err, valpass.ErrInvalidOptions) {
+		t.Errorf("invalid explained threshold not detected: %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package valpass
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DateMatch describes a date or year found in a password.
+type DateMatch struct {
+	Token     string // the part of the password forming the date
+	Start     int    // position of the first character, counted in characters
+	Length    int    // number of characters
+	Day       int    // day of month, 0 if not part of the date
+	Month     int    // month, 0 if not part of the date
+	Year      int    // year, always four digits
+	Name      string // month or season name, if any
+	Separator string // separator between day, month and year, if any
+}
+
+const (
+	DATE_MIN_YEAR int = 1900
+	DATE_MAX_YEAR int = 2050
+)
+
+var (
+	// numeric dates with separators, e.g. 12.03.84 or 1984-03-12
+	date_separated = regexp.MustCompile(`\d{1,4}[./_ -]\d{1,2}[./_ -]\d{1,4}`)
+
+	// numeric dates without separators and years
+	date_digits = regexp.MustCompile(`\d+`)
+
+	// month or season names next to a year, e.g. march2025 or 84summer
+	date_names = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|` +
+		`sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?|spring|summer|autumn|fall|winter)`
+	date_name_year = regexp.MustCompile(`(?i)` + date_names + `[ ./_-]?(\d{4}|\d{2})`)
+	date_year_name = regexp.MustCompile(`(?i)(\d{4}|\d{2})[ ./_-]?` + date_names)
+
+	date_seasons = []string{"spring", "summer", "autumn", "fall", "winter"}
+	date_days    = []int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+)
+
+// dateFinder carries the year range.
+type dateFinder struct {
+	passphrase string
+	minyear    int
+	maxyear    int
+	matches    []DateMatch
+}
+
+/*
+Return all dates  and years found in the  password: numeric dates in
+DMY, MDY and  YMD order with or without separators,  years within the
+given range and month or season names combined with a year.
+*/
+func getDates(passphrase string, minyear, maxyear int) []DateMatch {
+	if minyear == 0 {
+		minyear = DATE_MIN_YEAR
+	}
+
+	if maxyear == 0 {
+		maxyear = DATE_MAX_YEAR
+	}
+
+	finder := dateFinder{passphrase: passphrase, minyear: minyear, maxyear: maxyear}
+
+	for _, loc := range date_separated.FindAllStringIndex(passphrase, -1) {
+		finder.separated(loc[0], loc[1])
+	}
+
+	for _, loc := range date_digits.FindAllStringIndex(passphrase, -1) {
+		finder.digits(loc[0], loc[1])
+	}
+
+	for _, loc := range date_name_year.FindAllStringSubmatchIndex(passphrase, -1) {
+		finder.named(loc[0], loc[1], passphrase[loc[2]:loc[3]], passphrase[loc[4]:loc[5]])
+	}
+
+	for _, loc := range date_year_name.FindAllStringSubmatchIndex(passphrase, -1) {
+		finder.named(loc[0], loc[1], passphrase[loc[4]:loc[5]], passphrase[loc[2]:loc[3]])
+	}
+
+	return finder.result()
+}
+
+// Handle a numeric date with separators.
+func (finder *dateFinder) separated(start, end int) {
+	token := finder.passphrase[start:end]
+	fields := strings.FieldsFunc(token, func(char rune) bool {
+		return char < '0' || char > '9'
+	})
+
+	separator := strings.TrimLeft(token, "0123456789")[:1]
+	if len(fields) != 3 || strings.Count(token, separator) != 2 || !finder.bounded(start, end) {
+		return
+	}
+
+	// year first: YMD, otherwise DMY or MDY
+	if len(fields[0]) == 4 {
+		finder.add(start, end, fields[2], fields[1], fields[0], separator)
+		return
+	}
+
+	if len(fields[2]) == 2 || len(fields[2]) == 4 {
+		if !finder.add(start, end, fields[0], fields[1], fields[2], separator) {
+			finder.add(start, end, fields[1], fields[0], fields[2], separator)
+		}
+	}
+}
+
+// Handle a run of digits, which might be a year or a date without
+// separators.
+func (finder *dateFinder) digits(start, end int) {
+	token := finder.passphrase[start:end]
+
+	switch len(token) {
+	case 4:
+		if year := finder.year(token); year > 0 {
+			finder.matches = append(finder.matches, finder.newMatch(start, end, DateMatch{Year: year}))
+		}
+	case 6, 8:
+		yearlen := len(token) - 4
+
+		// DDMMYY(YY), MMDDYY(YY), YY(YY)MMDD
+		switch {
+		case finder.add(start, end, token[0:2], token[2:4], token[4:], ""):
+		case finder.add(start, end, token[2:4], token[0:2], token[4:], ""):
+		default:
+			finder.add(start, end, token[yearlen+2:], token[yearlen:yearlen+2], token[:yearlen], "")
+		}
+	}
+}
+
+// Handle a month or season name combined with a year.
+func (finder *dateFinder) named(start, end int, name, digits string) {
+	if !finder.bounded(start, end) {
+		return
+	}
+
+	year := finder.year(digits)
+	if year == 0 {
+		return
+	}
+
+	match := DateMatch{Year: year, Name: strings.ToLower(name)}
+
+	for _, season := range date_seasons {
+		if match.Name == season {
+			finder.matches = append(finder.matches, finder.newMatch(start, end, match))
+			return
+		}
+	}
+
+	months := []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	for i, month := range months {
+		if strings.HasPrefix(match.Name, month) {
+			match.Month = i + 1
+		}
+	}
+
+	finder.matches = append(finder.matches, finder.newMatch(start, end, match))
+}
+
+// Add a date if it's valid, returns false otherwise.
+func (finder *dateFinder) add(start, end int, day, month, year, separator string) bool {
+	d, _ := strconv.Atoi(day)
+	m, _ := strconv.Atoi(month)
+	y := finder.year(year)
+
+	if y == 0 || m < 1 || m > 12 || d < 1 || d > date_days[m-1] {
+		return false
+	}
+
+	finder.matches = append(finder.matches,
+		finder.newMatch(start, end, DateMatch{Day: d, Month: m, Year: y, Separator: separator}))
+
+	return true
+}
+
+// Returns  the four digit  year if  it is within  range, 0  otherwise.
+// Two digit years are expanded into the range, preferring the latest.
+func (finder *dateFinder) year(digits string) int {
+	year, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0
+	}
+
+	switch len(digits) {
+	case 2:
+		for century := finder.maxyear / 100 * 100; century >= finder.minyear/100*100; century -= 100 {
+			if century+year >= finder.minyear && century+year <= finder.maxyear {
+				return century + year
+			}
+		}
+
+		return 0
+	case 4:
+		if year >= finder.minyear && year <= finder.maxyear {
+			return year
+		}
+	}
+
+	return 0
+}
+
+// Returns true if the match is not surrounded by digits.
+func (finder *dateFinder) bounded(start, end int) bool {
+	isdigit := func(char byte) bool { return char >= '0' && char <= '9' }
+
+	return (start == 0 || !isdigit(finder.passphrase[start-1])) &&
+		(end == len(finder.passphrase) || !isdigit(finder.passphrase[end]))
+}
+
+// Fill in the position of the match, converting byte offsets to chars.
+func (finder *dateFinder) newMatch(start, end int, match DateMatch) DateMatch {
+	match.Token = finder.passphrase[start:end]
+	match.Start = utf8.RuneCountInString(finder.passphrase[:start])
+	match.Length = utf8.RuneCountInString(match.Token)
+
+	return match
+}
+
+// Returns the matches sorted by position, without those contained in
+// longer ones.
+func (finder *dateFinder) result() []DateMatch {
+	sort.SliceStable(finder.matches, func(i, j int) bool {
+		return finder.matches[i].Length > finder.matches[j].Length
+	})
+
+	dates := []DateMatch{}
+
+	for _, match := range finder.matches {
+		contained := false
+
+		for _, longer := range dates {
+			if match.Start >= longer.Start && match.Start+match.Length <= longer.Start+longer.Length {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			dates = append(dates, match)
+		}
+	}
+
+	sort.SliceStable(dates, func(i, j int) bool {
+		return dates[i].Start < dates[j].Start
+	})
+
+	return dates
+}
+
+// Returns a human readable description of the date.
+func (match DateMatch) message() string {
+	if match.Day == 0 && match.Month == 0 && match.Name == "" {
+		return fmt.Sprintf("password contains the year %q", match.Token)
+	}
+
+	return fmt.Sprintf("password contains the date %q", match.Token)
+}
@@ -0,0 +1,85 @@
+package valpass_test
+
+import (
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var pass_dates_bad = []string{
+	`19840312`, `12.03.84`, `Summer2026!`, `march2025`, `x1984-03-12`,
+	`03/12/1984`, `120384`, `born1999`, `84winter`, `Dec-2024`,
+	`12 03 1984`, `Jan19`,
+}
+
+var opts_dates = valpass.Options{
+	Dates: true,
+}
+
+func TestDates(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range pass_dates_bad {
+		CheckPassword(t, pass, Test{name: "checkbad-dates", want: false, opts: opts_dates})
+	}
+
+	for _, pass := range append(pass_random_good, pass_diceware_good...) {
+		CheckPassword(t, pass, Test{name: "checkgood-dates", want: true, opts: opts_dates})
+	}
+}
+
+func TestDateMatches(t *testing.T) {
+	t.Parallel()
+
+	var datetests = []struct {
+		pass  string
+		dates []valpass.DateMatch
+	}{
+		{`ä19840312`, []valpass.DateMatch{
+			{Token: "19840312", Start: 1, Length: 8, Day: 12, Month: 3, Year: 1984},
+		}},
+		{`12.03.84`, []valpass.DateMatch{
+			{Token: "12.03.84", Start: 0, Length: 8, Day: 12, Month: 3, Year: 1984, Separator: "."},
+		}},
+		{`03/25/2010`, []valpass.DateMatch{
+			{Token: "03/25/2010", Start: 0, Length: 10, Day: 25, Month: 3, Year: 2010, Separator: "/"},
+		}},
+		{`Summer2026!`, []valpass.DateMatch{
+			{Token: "Summer2026", Start: 0, Length: 10, Year: 2026, Name: "summer"},
+		}},
+		{`march25`, []valpass.DateMatch{
+			{Token: "march25", Start: 0, Length: 7, Month: 3, Year: 2025, Name: "march"},
+		}},
+		{`x1850y2049z`, []valpass.DateMatch{
+			{Token: "2049", Start: 6, Length: 4, Year: 2049},
+		}},
+		{`1234 99999 13.13.13`, []valpass.DateMatch{}},
+	}
+
+	for _, tt := range datetests {
+		result, err := valpass.Validate(tt.pass, opts_dates)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if len(result.Dates) != len(tt.dates) {
+			t.Fatalf("unexpected dates in %s. want: %v, got: %v", tt.pass, tt.dates, result.Dates)
+		}
+
+		for i, date := range result.Dates {
+			if date != tt.dates[i] {
+				t.Errorf("unexpected date in %s. want: %v, got: %v", tt.pass, tt.dates[i], date)
+			}
+		}
+	}
+
+	// a custom year range
+	result, err := valpass.Validate(`x1850y2049z`, valpass.Options{Dates: true, MinYear: 1800, MaxYear: 1900})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if len(result.Dates) != 1 || result.Dates[0].Year != 1850 {
+		t.Errorf("custom year range not respected: %v", result.Dates)
+	}
+}
@@ -0,0 +1,184 @@
+package valpass
+
+import (
+	"bufio"
+	"fmt"
+	"index/suffixarray"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// separates the words in the suffix array, it can't be part of a word
+const dict_separator byte = 0
+
+/*
+dictIndex contains the lookup  structures of a compiled dictionary:
+
+  - a hash set of all lower cased words for exact lookups,
+  - a suffix array over all lower cased words for submatch lookups,
+  - the words grouped by length for fuzzy lookups.
+
+Once built, it is only ever read, which makes it safe for concurrent
+use.
+*/
+type dictIndex struct {
+	words  []string           // the original words
+	exact  map[string]int     // lower cased word => index into words
+	lower  []string           // lower cased words, same order as words
+	starts []int              // start offset of every word in the suffix array
+	subs   *suffixarray.Index // all lower cased words, separated by dict_separator
+	bylen  map[int][]int      // rune length => indices into words
+	maxlen int                // length of the longest word in runes
+}
+
+/*
+Compile builds lookup indices for the dictionary. Afterwards exact
+matches are being looked up in constant time and submatches using a
+suffix array instead of scanning the whole word list on every
+validation.
+
+A compiled dictionary can be shared by  as many goroutines as you like
+and  be used  directly as  Options.Dictionary. Words  must not  be
+modified after compilation, call Compile() again if you need to.
+*/
+func (dict *Dictionary) Compile() error {
+	if len(dict.Words) < MIN_DICT_LEN {
+		return fmt.Errorf("provided dictionary is too small")
+	}
+
+	index := &dictIndex{
+		words:  dict.Words,
+		exact:  make(map[string]int, len(dict.Words)),
+		lower:  make([]string, len(dict.Words)),
+		starts: make([]int, len(dict.Words)),
+		bylen:  map[int][]int{},
+	}
+
+	var data strings.Builder
+
+	for id, word := range dict.Words {
+		lcword := strings.ToLower(word)
+		index.lower[id] = lcword
+
+		// keep the first occurrence, just like a linear scan would
+		if _, exists := index.exact[lcword]; !exists {
+			index.exact[lcword] = id
+		}
+
+		length := utf8.RuneCountInString(word)
+		index.bylen[length] = append(index.bylen[length], id)
+		index.maxlen = Max(index.maxlen, length)
+
+		index.starts[id] = data.Len()
+		data.WriteString(lcword)
+		data.WriteByte(dict_separator)
+	}
+
+	index.subs = suffixarray.New([]byte(data.String()))
+	dict.index = index
+
+	return nil
+}
+
+/*
+LoadDictionary reads a word list with one word per line, e.g.
+/usr/share/dict/words. Empty lines are skipped. The dictionary is not
+compiled, set the lookup options and call Compile() or use it with a
+Validator.
+*/
+func LoadDictionary(path string) (*Dictionary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dictionary: %w", err)
+	}
+
+	defer file.Close()
+
+	dict := &Dictionary{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if word := strings.TrimRight(scanner.Text(), "\r"); word != "" {
+			dict.Words = append(dict.Words, word)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+
+	return dict, nil
+}
+
+// Returns true if the dictionary has been compiled.
+func (dict *Dictionary) Compiled() bool {
+	return dict.index != nil
+}
+
+// wordSet answers lookups of lower cased words.
+type wordSet struct {
+	contains func(lcword string) bool
+	maxlen   int // length of the longest word in runes
+	size     int // number of distinct words
+}
+
+/*
+Returns the lookups of the dictionary. Compiled dictionaries use their
+index, uncompiled ones are converted into a hash set, which is
+expensive: build it once and pass it along.
+*/
+func (dict *Dictionary) wordSet() *wordSet {
+	if dict.index != nil {
+		return &wordSet{
+			contains: func(lcword string) bool {
+				_, ok := dict.index.exact[lcword]
+				return ok
+			},
+			maxlen: dict.index.maxlen,
+			size:   len(dict.index.exact),
+		}
+	}
+
+	words := make(map[string]bool, len(dict.Words))
+	maxlen := 0
+
+	for _, word := range dict.Words {
+		words[strings.ToLower(word)] = true
+		maxlen = Max(maxlen, utf8.RuneCountInString(word))
+	}
+
+	return &wordSet{
+		contains: func(lcword string) bool { return words[lcword] },
+		maxlen:   maxlen,
+		size:     len(words),
+	}
+}
+
+/*
+Lookup a  lower cased password. Exact  matches are looked up  in the
+hash set, submatches (the password  is part of a word) in the suffix
+array.
+*/
+func (index *dictIndex) lookup(lcpass string, submatch bool) dictMatch {
+	if id, ok := index.exact[lcpass]; ok {
+		return dictMatch{found: true, word: index.words[id], similarity: 1}
+	}
+
+	if !submatch || lcpass == "" || strings.IndexByte(lcpass, dict_separator) >= 0 {
+		return dictMatch{}
+	}
+
+	offsets := index.subs.Lookup([]byte(lcpass), 1)
+	if len(offsets) == 0 {
+		return dictMatch{}
+	}
+
+	// find the word containing the offset
+	id := sort.Search(len(index.starts), func(i int) bool {
+		return index.starts[i] > offsets[0]
+	}) - 1
+
+	return dictMatch{found: true, word: index.words[id], similarity: 1}
+}
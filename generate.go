@@ -0,0 +1,302 @@
+package valpass
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+const (
+	ALPHABET_UPPER   string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	ALPHABET_LOWER   string = "abcdefghijklmnopqrstuvwxyz"
+	ALPHABET_DIGITS  string = "0123456789"
+	ALPHABET_SYMBOLS string = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+	// all printable ASCII characters except space, 94 chars
+	ALPHABET_PRINTABLE string = ALPHABET_UPPER + ALPHABET_LOWER + ALPHABET_DIGITS + ALPHABET_SYMBOLS
+
+	GENERATOR_LENGTH   int = 20   // default password length
+	GENERATOR_ATTEMPTS int = 1000 // default number of passwords to try
+)
+
+// GeneratorOptions configures the passwords created by a Generator.
+type GeneratorOptions struct {
+	// Length is the number of characters, GENERATOR_LENGTH if zero.
+	Length int
+
+	// Alphabet contains the characters to choose from,
+	// ALPHABET_PRINTABLE if empty. Duplicates are ignored.
+	Alphabet string
+
+	// Forbidden characters are removed from the alphabet, e.g. chars
+	// a legacy system can't handle.
+	Forbidden string
+
+	// Require at least one character of the class. The Require*
+	// settings of the validation options are added to these.
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// StartWithLetter makes the first character a letter.
+	StartWithLetter bool
+
+	// Attempts is the  number of passwords to try until one passes the
+	// validation, GENERATOR_ATTEMPTS if zero.
+	Attempts int
+}
+
+/*
+Generator creates random passwords using crypto/rand. Every password
+satisfies the GeneratorOptions and passes Validate() under the
+validation options  the Generator has been created with. A Generator
+is safe for concurrent use.
+*/
+type Generator struct {
+	options   GeneratorOptions
+	validator *Validator
+	alphabet  []rune
+	first     []rune // candidates for the first character
+	required  []int  // required classes, see classIndex()
+}
+
+/*
+NewGenerator checks the generator and validation options and returns a
+new Generator. The validation options are used the same way as with
+NewValidator(), so start with DefaultOptions() if you want the
+defaults.
+
+It is an error if the constraints can't be met at all, e.g. a required
+class which has no characters in the alphabet or a length outside of
+MinLength and MaxLength.
+*/
+func NewGenerator(genopts GeneratorOptions, options Options) (*Generator, error) {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, fmt.Sprintf(format, args...))
+	}
+
+	if genopts.Length < 0 {
+		return nil, invalid("Length must not be negative")
+	}
+
+	if genopts.Attempts < 0 {
+		return nil, invalid("Attempts must not be negative")
+	}
+
+	if genopts.Length == 0 {
+		genopts.Length = GENERATOR_LENGTH
+	}
+
+	if genopts.Attempts == 0 {
+		genopts.Attempts = GENERATOR_ATTEMPTS
+	}
+
+	if genopts.Alphabet == "" {
+		genopts.Alphabet = ALPHABET_PRINTABLE
+	}
+
+	if options.MinLength > 0 && genopts.Length < options.MinLength {
+		return nil, invalid("Length %d is below MinLength %d", genopts.Length, options.MinLength)
+	}
+
+	if options.MaxLength > 0 && genopts.Length > options.MaxLength {
+		return nil, invalid("Length %d is above MaxLength %d", genopts.Length, options.MaxLength)
+	}
+
+	genopts.RequireUpper = genopts.RequireUpper || options.RequireUpper
+	genopts.RequireLower = genopts.RequireLower || options.RequireLower
+	genopts.RequireDigit = genopts.RequireDigit || options.RequireDigit
+	genopts.RequireSymbol = genopts.RequireSymbol || options.RequireSymbol
+
+	generator := &Generator{options: genopts}
+	seen := map[rune]bool{}
+	available := [4]bool{}
+
+	for _, char := range genopts.Alphabet {
+		if seen[char] || strings.ContainsRune(genopts.Forbidden, char) {
+			continue
+		}
+
+		if char == unicode.ReplacementChar || !unicode.IsPrint(char) {
+			return nil, invalid("Alphabet contains a non-printable character %q", char)
+		}
+
+		seen[char] = true
+		generator.alphabet = append(generator.alphabet, char)
+		available[classIndex(char)] = true
+
+		if unicode.IsLetter(char) {
+			generator.first = append(generator.first, char)
+		}
+	}
+
+	if len(generator.alphabet) == 0 {
+		return nil, invalid("Alphabet is empty without the forbidden characters")
+	}
+
+	if !genopts.StartWithLetter {
+		generator.first = generator.alphabet
+	} else if len(generator.first) == 0 {
+		return nil, invalid("StartWithLetter requires letters in the Alphabet")
+	}
+
+	for index, required := range []bool{genopts.RequireUpper, genopts.RequireLower,
+		genopts.RequireDigit, genopts.RequireSymbol} {
+		if !required {
+			continue
+		}
+
+		if !available[index] {
+			return nil, invalid("Alphabet contains no %s", classNames[index])
+		}
+
+		generator.required = append(generator.required, index)
+	}
+
+	if len(generator.required) > genopts.Length {
+		return nil, invalid("Length %d is too short for %d required classes",
+			genopts.Length, len(generator.required))
+	}
+
+	validator, err := NewValidator(options)
+	if err != nil {
+		return nil, err
+	}
+
+	generator.validator = validator
+
+	return generator, nil
+}
+
+/*
+Generate returns a new random password. Passwords which miss a required
+class or don't pass the validation are discarded, so every password
+which meets the constraints is equally likely. An error is returned if
+no password passed within the configured number of attempts, which
+usually means the validation options are too strict for the length or
+alphabet.
+*/
+func (generator *Generator) Generate() (string, error) {
+	for attempt := 0; attempt < generator.options.Attempts; attempt++ {
+		passphrase, err := generator.random()
+		if err != nil {
+			return "", err
+		}
+
+		if !generator.hasRequired(passphrase) {
+			continue
+		}
+
+		result, err := generator.validator.Validate(passphrase)
+		if err != nil {
+			return "", err
+		}
+
+		if result.Ok {
+			return passphrase, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to generate a valid password within %d attempts",
+		generator.options.Attempts)
+}
+
+// Returns a random password of the configured length.
+func (generator *Generator) random() (string, error) {
+	password := make([]rune, generator.options.Length)
+
+	for i := range password {
+		chars := generator.alphabet
+		if i == 0 {
+			chars = generator.first
+		}
+
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", fmt.Errorf("failed to read random data: %w", err)
+		}
+
+		password[i] = chars[index.Int64()]
+	}
+
+	return string(password), nil
+}
+
+// Returns true if the password contains all required classes.
+func (generator *Generator) hasRequired(passphrase string) bool {
+	used := [4]bool{}
+	for _, char := range passphrase {
+		used[classIndex(char)] = true
+	}
+
+	for _, index := range generator.required {
+		if !used[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Entropy returns the theoretical entropy of the generated passwords in
+bits, that is log2 of the number of passwords which meet the length,
+alphabet, class and first character  constraints. Passwords rejected
+by the validation are not subtracted, their share is usually tiny.
+*/
+func (generator *Generator) Entropy() float64 {
+	// inclusion-exclusion over the required classes: count the
+	// passwords without the classes of each subset, add or subtract
+	// them depending on the size of the subset
+	total := new(big.Int)
+	subsets := 1 << len(generator.required)
+
+	for subset := 0; subset < subsets; subset++ {
+		excluded := [4]bool{}
+		size := 0
+
+		for bit, index := range generator.required {
+			if subset&(1<<bit) != 0 {
+				excluded[index] = true
+				size++
+			}
+		}
+
+		var chars, first int64
+
+		for _, char := range generator.alphabet {
+			if excluded[classIndex(char)] {
+				continue
+			}
+
+			chars++
+
+			if !generator.options.StartWithLetter || unicode.IsLetter(char) {
+				first++
+			}
+		}
+
+		count := new(big.Int).Exp(big.NewInt(chars), big.NewInt(int64(generator.options.Length-1)), nil)
+		count.Mul(count, big.NewInt(first))
+
+		if size%2 == 0 {
+			total.Add(total, count)
+		} else {
+			total.Sub(total, count)
+		}
+	}
+
+	if total.Sign() <= 0 {
+		return 0
+	}
+
+	mantissa := new(big.Float)
+	exponent := new(big.Float).SetInt(total).MantExp(mantissa)
+	value, _ := mantissa.Float64()
+
+	return math.Log2(value) + float64(exponent)
+}
@@ -0,0 +1,129 @@
+package valpass_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/tlinden/valpass"
+)
+
+var opts_generator_invalid = map[string]struct {
+	genopts valpass.GeneratorOptions
+	opts    valpass.Options
+}{
+	"negative-length":  {genopts: valpass.GeneratorOptions{Length: -1}},
+	"empty-alphabet":   {genopts: valpass.GeneratorOptions{Alphabet: "abc", Forbidden: "cba"}},
+	"missing-class":    {genopts: valpass.GeneratorOptions{Alphabet: "abc", RequireDigit: true}},
+	"policy-class":     {genopts: valpass.GeneratorOptions{Alphabet: "abc"}, opts: valpass.Options{RequireUpper: true}},
+	"no-letter":        {genopts: valpass.GeneratorOptions{Alphabet: "0123", StartWithLetter: true}},
+	"too-short":        {genopts: valpass.GeneratorOptions{Length: 8}, opts: valpass.Options{MinLength: 12}},
+	"too-long":         {genopts: valpass.GeneratorOptions{Length: 20}, opts: valpass.Options{MaxLength: 16}},
+	"too-many-classes": {genopts: valpass.GeneratorOptions{Length: 2, RequireUpper: true, RequireLower: true, RequireDigit: true}},
+	"non-printable":    {genopts: valpass.GeneratorOptions{Alphabet: "abc\x07"}},
+	"invalid-options":  {opts: valpass.Options{Entropy: -1}},
+}
+
+func TestGeneratorOptions(t *testing.T) {
+	t.Parallel()
+
+	for name, tt := range opts_generator_invalid {
+		generator, err := valpass.NewGenerator(tt.genopts, tt.opts)
+		if err == nil || generator != nil || !errors.Is(err, valpass.ErrInvalidOptions) {
+			t.Errorf("%s: invalid options not detected: %v", name, err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.DefaultOptions()
+	opts.MinClasses = 4
+	opts.Dictionary = opts_dict.Dictionary
+
+	genopts := valpass.GeneratorOptions{
+		Length:          16,
+		Forbidden:       `"'\` + "`O0l1",
+		RequireSymbol:   true,
+		StartWithLetter: true,
+	}
+
+	generator, err := valpass.NewGenerator(genopts, opts)
+	if err != nil {
+		t.Fatalf("failed to create generator: %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		password, err := generator.Generate()
+		if err != nil {
+			t.Fatalf("failed to generate password: %s", err)
+		}
+
+		if len([]rune(password)) != genopts.Length {
+			t.Errorf("generated password has length %d, want %d", len([]rune(password)), genopts.Length)
+		}
+
+		if strings.ContainsAny(password, genopts.Forbidden) {
+			t.Errorf("generated password contains forbidden chars")
+		}
+
+		if !unicode.IsLetter([]rune(password)[0]) {
+			t.Errorf("generated password doesn't start with a letter")
+		}
+
+		result, err := valpass.Validate(password, opts)
+		if err != nil || !result.Ok {
+			t.Errorf("generated password doesn't pass: %v %v", err, result.Failures)
+		}
+	}
+}
+
+func TestGenerateImpossible(t *testing.T) {
+	t.Parallel()
+
+	// 4 digits never reach the default entropy
+	generator, err := valpass.NewGenerator(
+		valpass.GeneratorOptions{Length: 4, Alphabet: valpass.ALPHABET_DIGITS, Attempts: 10},
+		valpass.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create generator: %s", err)
+	}
+
+	if password, err := generator.Generate(); err == nil {
+		t.Errorf("impossible password generated: %q", password)
+	}
+}
+
+func TestGeneratorEntropy(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		name    string
+		genopts valpass.GeneratorOptions
+		want    float64
+	}{
+		{"printable", valpass.GeneratorOptions{Length: 10}, 10 * math.Log2(94)},
+		{"duplicates", valpass.GeneratorOptions{Length: 8, Alphabet: "aabbccdd"}, 8 * 2},
+		{"forbidden", valpass.GeneratorOptions{Length: 8, Alphabet: "abcdefgh", Forbidden: "efgh"}, 8 * 2},
+		{"first-letter", valpass.GeneratorOptions{Length: 4, Alphabet: "ab01", StartWithLetter: true}, 1 + 3*2},
+		// 2 chars from "a0": "a0" and "0a" contain both classes
+		{"required", valpass.GeneratorOptions{Length: 2, Alphabet: "a0", RequireLower: true, RequireDigit: true}, 1},
+		// 3 chars from "ab0": 27 - 8 without digit - 1 without letter
+		{"required-3", valpass.GeneratorOptions{Length: 3, Alphabet: "ab0", RequireLower: true, RequireDigit: true},
+			math.Log2(18)},
+	}
+
+	for _, tt := range tests {
+		generator, err := valpass.NewGenerator(tt.genopts, valpass.Options{})
+		if err != nil {
+			t.Fatalf("%s: failed to create generator: %s", tt.name, err)
+		}
+
+		if got := generator.Entropy(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: entropy %f, want %f", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,596 @@
+package valpass
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+The guess estimator  is modelled after zxcvbn: the  password is split
+into the  cheapest sequence of  matches (dictionary words,  keyboard
+walks,  sequences, dates  and brute  force  segments) and  the number
+of guesses an attacker  who knows these patterns needs  is derived
+from it.
+*/
+
+// CrackTimes contains estimated times to crack a password in seconds.
+type CrackTimes struct {
+	OnlineThrottled   float64 // online attack, 100 guesses per hour
+	OnlineUnthrottled float64 // online attack, 10 guesses per second
+	OfflineSlowHash   float64 // offline attack on a slow hash, 1e4 guesses per second
+	OfflineFastHash   float64 // offline attack on a fast hash, 1e10 guesses per second
+}
+
+const (
+	MIN_GUESSES_LOG10 float64 = 10
+
+	// guesses per second of the attack scenarios
+	rate_online_throttled   float64 = 100.0 / 3600
+	rate_online_unthrottled float64 = 10
+	rate_offline_slow       float64 = 1e4
+	rate_offline_fast       float64 = 1e10
+
+	bruteforce_cardinality    float64 = 10
+	min_guesses_single_char   float64 = 10
+	min_guesses_multi_char    float64 = 50
+	min_guesses_before_growth float64 = 10000
+	min_year_space            float64 = 20
+	min_dict_token            int     = 3
+
+	// longer passwords are scored in parts of this length
+	guesses_max_length int = 100
+
+	// guesses is capped to keep it finite
+	max_guesses float64 = 1e300
+)
+
+// guessMatch is a part of the password with its estimated guesses.
+type guessMatch struct {
+	i, j    int // first and last char, inclusive
+	guesses float64
+	brute   bool // brute force match
+}
+
+// state of the optimal match sequence ending at some position
+type guessState struct {
+	guesses float64 // overall guesses of the sequence
+	product float64 // product of the guesses of the matches
+	match   guessMatch
+}
+
+// guessDict is a dictionary used by the estimator, together with its
+// lookups, which are built once per validation.
+type guessDict struct {
+	dict  *Dictionary
+	words *wordSet
+}
+
+// guessConfig contains the patterns the estimator looks for.
+type guessConfig struct {
+	dicts            []guessDict
+	layouts          []string // keyboard layouts, all registered if empty
+	minyear, maxyear int
+}
+
+/*
+Return the minimum number of guesses needed to find the password. The
+dictionaries (the configured one and the user inputs) are optional,
+the other patterns are always considered.
+
+The estimator needs quadratic time, therefore longer passwords are
+split into  parts of  guesses_max_length chars which  are scored one
+by one. A part which occurs more than once is scored only once and
+multiplied by the number of its occurrences, like a repeated block.
+Passwords  which repeat a shorter  block as  a whole are  scored by
+that block.
+*/
+func getGuesses(passphrase string, config guessConfig) float64 {
+	chars := []rune(passphrase)
+	if len(chars) == 0 {
+		return 1
+	}
+
+	if len(chars) <= guesses_max_length {
+		matches := getGuessMatches(passphrase, chars, config)
+
+		return math.Min(estimateGuesses(chars, matches), max_guesses)
+	}
+
+	if period := getPeriod(chars); period <= len(chars)/2 {
+		// the last repetition may be incomplete
+		repeats := (len(chars) + period - 1) / period
+
+		return math.Min(getGuesses(string(chars[:period]), config)*float64(repeats), max_guesses)
+	}
+
+	parts := []string{}
+	count := map[string]int{}
+
+	for start := 0; start < len(chars); start += guesses_max_length {
+		part := string(chars[start:Min(start+guesses_max_length, len(chars))])
+
+		if count[part] == 0 {
+			parts = append(parts, part)
+		}
+
+		count[part]++
+	}
+
+	guesses := 1.0
+
+	for _, part := range parts {
+		guesses *= getGuesses(part, config) * float64(count[part])
+
+		if guesses >= max_guesses {
+			break
+		}
+	}
+
+	return math.Min(guesses, max_guesses)
+}
+
+/*
+Returns the length  of the shortest block the chars  are made of, e.g.
+3 for "abcabcab". The length of the chars if there is no such block.
+*/
+func getPeriod(chars []rune) int {
+	// length of the longest proper prefix which is also a suffix,
+	// see the Knuth-Morris-Pratt algorithm
+	border := make([]int, len(chars))
+
+	for i := 1; i < len(chars); i++ {
+		k := border[i-1]
+		for k > 0 && chars[i] != chars[k] {
+			k = border[k-1]
+		}
+
+		if chars[i] == chars[k] {
+			k++
+		}
+
+		border[i] = k
+	}
+
+	return len(chars) - border[len(chars)-1]
+}
+
+// Returns the crack times for the given number of guesses.
+func getCrackTimes(guesses float64) CrackTimes {
+	return CrackTimes{
+		OnlineThrottled:   guesses / rate_online_throttled,
+		OnlineUnthrottled: guesses / rate_online_unthrottled,
+		OfflineSlowHash:   guesses / rate_offline_slow,
+		OfflineFastHash:   guesses / rate_offline_fast,
+	}
+}
+
+// Collect all matches of all patterns.
+func getGuessMatches(passphrase string, chars []rune, config guessConfig) []guessMatch {
+	matches := []guessMatch{}
+
+	for _, dict := range config.dicts {
+		matches = append(matches, getDictGuessMatches(chars, dict)...)
+	}
+
+	if walks, err := getKeyboardWalks(passphrase, config.layouts); err == nil {
+		for _, walk := range walks {
+			matches = append(matches, guessMatch{
+				i:       walk.Start,
+				j:       walk.Start + walk.Length - 1,
+				guesses: walkGuesses(walk),
+			})
+		}
+	}
+
+	for _, sequence := range getSequences(passphrase) {
+		matches = append(matches, guessMatch{
+			i:       sequence.Start,
+			j:       sequence.Start + sequence.Length - 1,
+			guesses: sequenceGuesses(sequence, config),
+		})
+	}
+
+	for _, date := range getDates(passphrase, config.minyear, config.maxyear) {
+		matches = append(matches, guessMatch{
+			i:       date.Start,
+			j:       date.Start + date.Length - 1,
+			guesses: dateGuesses(date),
+		})
+	}
+
+	// submatches can't be cheaper than some minimum
+	for id, match := range matches {
+		if match.j-match.i+1 < len(chars) {
+			minimum := min_guesses_multi_char
+			if match.i == match.j {
+				minimum = min_guesses_single_char
+			}
+
+			matches[id].guesses = math.Max(match.guesses, minimum)
+		}
+	}
+
+	return matches
+}
+
+/*
+Find all dictionary words in the password, also reversed and with l33t
+substitutions undone, if enabled. Every word counts as much guesses as
+the dictionary contains words, multiplied by the possible case and l33t
+variations.
+*/
+func getDictGuessMatches(chars []rune, lookup guessDict) []guessMatch {
+	dict := lookup.dict
+	contains, maxlen := lookup.words.contains, lookup.words.maxlen
+	size := float64(Max(len(dict.Words), 1))
+
+	lower := []rune(strings.ToLower(string(chars)))
+	if len(lower) != len(chars) {
+		// case folding changed the length, don't bother
+		return nil
+	}
+
+	matches := []guessMatch{}
+
+	candidates := []leetCandidate{{word: string(lower)}}
+	if dict.Leet {
+		candidates = append(candidates, getLeetCandidates(string(lower), dict.LeetTable)...)
+	}
+
+	for _, candidate := range candidates {
+		word := []rune(candidate.word)
+		if len(word) != len(chars) {
+			continue
+		}
+
+		for i := 0; i < len(word); i++ {
+			for j := i + min_dict_token - 1; j < len(word) && j-i < maxlen; j++ {
+				leet := leetVariations(chars[i:j+1], word[i:j+1])
+				if len(candidate.substitutions) > 0 && leet == 1 {
+					// no substitution within this token, found without l33t
+					continue
+				}
+
+				guesses := size * caseVariations(chars[i:j+1]) * leet
+
+				if contains(string(word[i : j+1])) {
+					matches = append(matches, guessMatch{i: i, j: j, guesses: guesses})
+				}
+
+				if contains(reverseString(string(word[i : j+1]))) {
+					matches = append(matches, guessMatch{i: i, j: j, guesses: guesses * 2})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// Returns the number of upper/lower case variations of a token.
+func caseVariations(token []rune) float64 {
+	upper, lower := 0, 0
+
+	for _, char := range token {
+		switch {
+		case unicode.IsUpper(char):
+			upper++
+		case unicode.IsLower(char):
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	// first or last char upper case, or all upper case
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(token[0]) || unicode.IsUpper(token[len(token)-1]))) {
+		return 2
+	}
+
+	variations := 0.0
+	for i := 1; i <= Min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+
+	return variations
+}
+
+// Returns the number of l33t variations, comparing the original token
+// with the de-l33ted one.
+func leetVariations(token, word []rune) float64 {
+	subbed := map[rune]int{}
+	unsubbed := map[rune]int{}
+
+	for i := range token {
+		if unicode.ToLower(token[i]) != word[i] {
+			subbed[word[i]]++
+		}
+	}
+
+	if len(subbed) == 0 {
+		return 1
+	}
+
+	for i := range word {
+		if _, ok := subbed[word[i]]; ok && unicode.ToLower(token[i]) == word[i] {
+			unsubbed[word[i]]++
+		}
+	}
+
+	variations := 1.0
+
+	for char, s := range subbed {
+		u := unsubbed[char]
+		if u == 0 {
+			variations *= 2
+			continue
+		}
+
+		possibilities := 0.0
+		for i := 1; i <= Min(s, u); i++ {
+			possibilities += binomial(s+u, i)
+		}
+
+		variations *= possibilities
+	}
+
+	return variations
+}
+
+// Guesses of a keyboard walk, see zxcvbn's spatial matching.
+func walkGuesses(walk KeyboardWalk) float64 {
+	graphs, err := getKeyboardGraphs([]string{walk.Layout})
+	if err != nil {
+		return max_guesses
+	}
+
+	// shifted chars share the key, layouts like the keypad have none
+	keys := 0.0
+	for _, position := range graphs[0].positions {
+		if !position.shifted {
+			keys++
+		}
+	}
+	degree := float64(len(graphs[0].neighbours))
+	guesses := 0.0
+
+	for i := 2; i <= walk.Length; i++ {
+		for j := 1; j <= Min(walk.Turns+1, i-1); j++ {
+			guesses += binomial(i-1, j-1) * keys * math.Pow(degree, float64(j))
+		}
+	}
+
+	// shifted keys add variations just like upper case letters
+	if walk.Shifted > 0 {
+		unshifted := walk.Length - walk.Shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= Min(walk.Shifted, unshifted); i++ {
+				variations += binomial(walk.Length, i)
+			}
+
+			guesses *= variations
+		}
+	}
+
+	return guesses
+}
+
+// Guesses of a sequence or repetition.
+func sequenceGuesses(sequence Sequence, config guessConfig) float64 {
+	chars := []rune(sequence.Token)
+
+	switch sequence.Kind {
+	case SEQUENCE_ASCENDING, SEQUENCE_DESCENDING:
+		base := 26.0
+
+		switch first := unicode.ToLower(chars[0]); {
+		case strings.ContainsRune("az019", first):
+			// obvious start
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		case !unicode.IsLetter(first):
+			base = 95
+		}
+
+		if sequence.Kind == SEQUENCE_DESCENDING {
+			base *= 2
+		}
+
+		return base * float64(len(chars))
+	case SEQUENCE_REPEAT:
+		return charCardinality(chars[0]) * float64(len(chars))
+	case SEQUENCE_BLOCK:
+		// estimate the guesses of the block itself and repeat it
+		for size := 2; size <= len(chars)/2; size++ {
+			if len(chars)%size == 0 && strings.Repeat(string(chars[:size]), len(chars)/size) == sequence.Token {
+				return getGuesses(string(chars[:size]), config) * float64(len(chars)/size)
+			}
+		}
+
+		return getGuesses(string(chars[:len(chars)/2]), config) * 2
+	case SEQUENCE_MIRROR:
+		return getGuesses(string(chars[:(len(chars)+1)/2]), config) * 2
+	}
+
+	return max_guesses
+}
+
+// Guesses of a date or year.
+func dateGuesses(date DateMatch) float64 {
+	space := math.Max(math.Abs(float64(date.Year-time.Now().Year())), min_year_space)
+
+	switch {
+	case date.Day > 0:
+		guesses := space * 365
+		if date.Separator != "" {
+			guesses *= 4
+		}
+
+		return guesses
+	case date.Name != "":
+		// 12 months plus 5 season names, in different cases
+		return space * 17 * 2
+	default:
+		return space
+	}
+}
+
+// Returns the size of the character class of a char.
+func charCardinality(char rune) float64 {
+	switch {
+	case unicode.IsDigit(char):
+		return 10
+	case unicode.IsLetter(char):
+		return 26
+	default:
+		return 33
+	}
+}
+
+/*
+Find the sequence  of non-overlapping matches covering  the password
+which needs the  least guesses. Gaps are filled  with brute force
+matches. See zxcvbn's most_guessable_match_sequence() for details.
+*/
+func estimateGuesses(chars []rune, matches []guessMatch) float64 {
+	length := len(chars)
+
+	byend := make([][]guessMatch, length)
+	for _, match := range matches {
+		byend[match.j] = append(byend[match.j], match)
+	}
+
+	// optimal[k][l]: best sequence of l matches covering chars 0..k
+	optimal := make([]map[int]guessState, length)
+	for k := range optimal {
+		optimal[k] = map[int]guessState{}
+	}
+
+	update := func(match guessMatch, count int) {
+		k := match.j
+		product := match.guesses
+
+		if count > 1 {
+			product *= optimal[match.i-1][count-1].product
+		}
+
+		guesses := factorial(count)*product + math.Pow(min_guesses_before_growth, float64(count-1))
+
+		// a shorter sequence which needs less guesses is preferred
+		for other, state := range optimal[k] {
+			if other <= count && state.guesses <= guesses {
+				return
+			}
+		}
+
+		optimal[k][count] = guessState{guesses: guesses, product: product, match: match}
+	}
+
+	bruteforce := func(i, j int) guessMatch {
+		guesses := math.Max(math.Pow(bruteforce_cardinality, float64(j-i+1)), min_guesses_single_char+1)
+		if j > i {
+			guesses = math.Max(guesses, min_guesses_multi_char+1)
+		}
+
+		return guessMatch{i: i, j: j, guesses: math.Min(guesses, max_guesses), brute: true}
+	}
+
+	for k := 0; k < length; k++ {
+		for _, match := range byend[k] {
+			if match.i == 0 {
+				update(match, 1)
+				continue
+			}
+
+			for count := range optimal[match.i-1] {
+				update(match, count+1)
+			}
+		}
+
+		update(bruteforce(0, k), 1)
+
+		for i := 1; i <= k; i++ {
+			brute := bruteforce(i, k)
+
+			for count, state := range optimal[i-1] {
+				// adjacent brute force matches are one match
+				if state.match.brute {
+					continue
+				}
+
+				update(brute, count+1)
+			}
+		}
+	}
+
+	guesses := math.Inf(1)
+	for _, state := range optimal[length-1] {
+		guesses = math.Min(guesses, state.guesses)
+	}
+
+	return math.Min(guesses, max_guesses)
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+
+	return result
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+
+	return result
+}
+
+func reverseString(text string) string {
+	chars := []rune(text)
+
+	for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+
+	return string(chars)
+}
+
+// Returns a human readable duration of the given seconds.
+func humanDuration(seconds float64) string {
+	units := []struct {
+		name    string
+		seconds float64
+	}{
+		{"centuries", 100 * 365.25 * 86400},
+		{"years", 365.25 * 86400},
+		{"months", 30.44 * 86400},
+		{"days", 86400},
+		{"hours", 3600},
+		{"minutes", 60},
+	}
+
+	for _, unit := range units {
+		if seconds >= unit.seconds {
+			return fmt.Sprintf("%.0f %s", seconds/unit.seconds, unit.name)
+		}
+	}
+
+	return "less than a minute"
+}
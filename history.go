@@ -0,0 +1,466 @@
+package valpass
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	HISTORY_SIZE       int = 10   // default number of previous passwords to keep
+	HISTORY_ITERATIONS int = 1000 // default PBKDF2 iterations
+
+	// Every comparison of a password variant with an entry runs PBKDF2
+	// with the iterations of the entry, that is one HMAC-SHA256 per
+	// iteration. Entries with more iterations are ignored, only the
+	// newest HISTORY_MAX_ENTRIES entries of a store are checked and a
+	// lookup stops comparing once HISTORY_MAX_WORK iterations have been
+	// spent, so it costs at most that many HMACs, about 100ms. This
+	// covers all variants (up to 16) of a history with the default size
+	// and iterations, more or stronger hashed entries are checked
+	// partially.
+	HISTORY_MAX_ITERATIONS int = 100000
+	HISTORY_MAX_ENTRIES    int = 24
+	HISTORY_MAX_WORK       int = 300000
+
+	HISTORY_EXACT  string = "exact"  // the password has been used before
+	HISTORY_CASE   string = "case"   // the password differs only in case
+	HISTORY_DIGITS string = "digits" // the password differs only in trailing digits
+
+	history_salt_len int    = 16
+	history_key_len  int    = 32
+	history_min_key  int    = 16 // shorter hashes are ignored
+	history_scheme   string = "pbkdf2-sha256"
+)
+
+// HistoryEntry is a previous password, salted and hashed with
+// PBKDF2-HMAC-SHA256.
+type HistoryEntry struct {
+	Salt       []byte
+	Hash       []byte
+	Iterations int
+}
+
+/*
+HistoryStore  can be implemented  to keep track of previous passwords
+of a user. Add records a password  which has been accepted, Entries
+returns the stored ones. Validate() only ever reads from the store, it
+is up to  the caller to Add() the  new password once it  has been set.
+
+Implementations must never store  plain text passwords, use
+NewHistoryEntry() to create entries.
+*/
+type HistoryStore interface {
+	Add(passphrase string) error
+	Entries() ([]HistoryEntry, error)
+}
+
+// NewHistoryEntry  salts  and hashes the  password with the given number
+// of iterations, HISTORY_ITERATIONS if zero, HISTORY_MAX_ITERATIONS at
+// most.
+func NewHistoryEntry(passphrase string, iterations int) (HistoryEntry, error) {
+	if iterations <= 0 {
+		iterations = HISTORY_ITERATIONS
+	}
+
+	if iterations > HISTORY_MAX_ITERATIONS {
+		return HistoryEntry{}, fmt.Errorf("iterations must not exceed %d", HISTORY_MAX_ITERATIONS)
+	}
+
+	salt := make([]byte, history_salt_len)
+	if _, err := rand.Read(salt); err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return HistoryEntry{
+		Salt:       salt,
+		Hash:       pbkdf2SHA256([]byte(passphrase), salt, iterations, history_key_len),
+		Iterations: iterations,
+	}, nil
+}
+
+// Matches returns true if the entry is a hash of the given password.
+// Entries with a hash shorter than 16 bytes or invalid iterations never
+// match.
+func (entry HistoryEntry) Matches(passphrase string) bool {
+	if !entry.valid() {
+		return false
+	}
+
+	hash := pbkdf2SHA256([]byte(passphrase), entry.Salt, entry.Iterations, len(entry.Hash))
+
+	return hmac.Equal(hash, entry.Hash)
+}
+
+// Returns true if the entry can be compared at all.
+func (entry HistoryEntry) valid() bool {
+	return len(entry.Hash) >= history_min_key &&
+		entry.Iterations > 0 && entry.Iterations <= HISTORY_MAX_ITERATIONS
+}
+
+// MemoryHistory is a HistoryStore which keeps the entries in memory.
+// It is safe for concurrent use.
+type MemoryHistory struct {
+	// Size is the number of previous passwords to keep.
+	Size int
+
+	// Iterations is the number of PBKDF2 iterations for new entries.
+	Iterations int
+
+	mutex   sync.Mutex
+	entries []HistoryEntry
+}
+
+// NewMemoryHistory returns a new MemoryHistory keeping the last size
+// passwords, HISTORY_SIZE if zero.
+func NewMemoryHistory(size int) *MemoryHistory {
+	if size <= 0 {
+		size = HISTORY_SIZE
+	}
+
+	return &MemoryHistory{Size: size, Iterations: HISTORY_ITERATIONS}
+}
+
+func (history *MemoryHistory) Add(passphrase string) error {
+	entry, err := NewHistoryEntry(passphrase, history.Iterations)
+	if err != nil {
+		return err
+	}
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	history.entries = lastEntries(append(history.entries, entry), history.Size)
+
+	return nil
+}
+
+func (history *MemoryHistory) Entries() ([]HistoryEntry, error) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	return append([]HistoryEntry{}, history.entries...), nil
+}
+
+/*
+FileHistory is a HistoryStore which keeps the entries in a file, one
+entry per line:
+
+	pbkdf2-sha256:<iterations>:<hex salt>:<hex hash>
+
+The file is created with  mode 0600 if it doesn't exist and replaced
+atomically on every Add(). Concurrent use within one process is safe,
+multiple processes writing the same file are not synchronized.
+*/
+type FileHistory struct {
+	// Path is the history file.
+	Path string
+
+	// Size is the number of previous passwords to keep.
+	Size int
+
+	// Iterations is the number of PBKDF2 iterations for new entries.
+	Iterations int
+
+	mutex sync.Mutex
+}
+
+// NewFileHistory returns a new FileHistory keeping the last size
+// passwords, HISTORY_SIZE if zero.
+func NewFileHistory(path string, size int) *FileHistory {
+	if size <= 0 {
+		size = HISTORY_SIZE
+	}
+
+	return &FileHistory{Path: path, Size: size, Iterations: HISTORY_ITERATIONS}
+}
+
+func (history *FileHistory) Add(passphrase string) error {
+	entry, err := NewHistoryEntry(passphrase, history.Iterations)
+	if err != nil {
+		return err
+	}
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	entries, err := history.read()
+	if err != nil {
+		return err
+	}
+
+	entries = lastEntries(append(entries, entry), history.Size)
+
+	var content strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&content, "%s:%d:%s:%s\n", history_scheme, entry.Iterations,
+			hex.EncodeToString(entry.Salt), hex.EncodeToString(entry.Hash))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(history.Path), ".valpass-history-*")
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content.String()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), history.Path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
+
+func (history *FileHistory) Entries() ([]HistoryEntry, error) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	return history.read()
+}
+
+// Read all entries, a missing file is an empty history.
+func (history *FileHistory) read() ([]HistoryEntry, error) {
+	file, err := os.Open(history.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	defer file.Close()
+
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		entry, err := parseHistoryEntry(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("invalid history entry in %s line %d: %w", history.Path, line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+func parseHistoryEntry(line string) (HistoryEntry, error) {
+	fields := strings.Split(line, ":")
+	if len(fields) != 4 || fields[0] != history_scheme {
+		return HistoryEntry{}, fmt.Errorf("expected %s:<iterations>:<salt>:<hash>", history_scheme)
+	}
+
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil || iterations <= 0 || iterations > HISTORY_MAX_ITERATIONS {
+		return HistoryEntry{}, fmt.Errorf("invalid iterations %q", fields[1])
+	}
+
+	salt, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	hash, err := hex.DecodeString(fields[3])
+	if err != nil || len(hash) < history_min_key {
+		return HistoryEntry{}, fmt.Errorf("invalid hash %q", fields[3])
+	}
+
+	return HistoryEntry{Salt: salt, Hash: hash, Iterations: iterations}, nil
+}
+
+// Returns the last size entries.
+func lastEntries(entries []HistoryEntry, size int) []HistoryEntry {
+	if size > 0 && len(entries) > size {
+		return entries[len(entries)-size:]
+	}
+
+	return entries
+}
+
+// historyVariant is a mutation of the new password.
+type historyVariant struct {
+	word string
+	kind string
+}
+
+/*
+Return  the  password and  its common  mutations, which are  looked up
+in the history  because near-variants can't be  found by  comparing
+hashes: case flips (all lower, all upper, swapped, first letter) and
+trailing digit changes (number incremented or decremented, last digit
+replaced, digits removed, a digit appended if there are none, because
+the previous password may have had one).
+*/
+func getHistoryVariants(passphrase string) []historyVariant {
+	variants := []historyVariant{{word: passphrase, kind: HISTORY_EXACT}}
+	seen := map[string]bool{passphrase: true}
+
+	add := func(word, kind string) {
+		if !seen[word] {
+			seen[word] = true
+			variants = append(variants, historyVariant{word: word, kind: kind})
+		}
+	}
+
+	add(strings.ToLower(passphrase), HISTORY_CASE)
+	add(strings.ToUpper(passphrase), HISTORY_CASE)
+	add(strings.Map(func(char rune) rune {
+		if unicode.IsUpper(char) {
+			return unicode.ToLower(char)
+		}
+
+		return unicode.ToUpper(char)
+	}, passphrase), HISTORY_CASE)
+
+	if first, size := utf8.DecodeRuneInString(passphrase); unicode.IsLetter(first) {
+		flipped := unicode.ToUpper(first)
+		if unicode.IsUpper(first) {
+			flipped = unicode.ToLower(first)
+		}
+
+		add(string(flipped)+passphrase[size:], HISTORY_CASE)
+	}
+
+	prefix := strings.TrimRight(passphrase, "0123456789")
+	digits := passphrase[len(prefix):]
+
+	if digits == "" {
+		for digit := '0'; digit <= '9'; digit++ {
+			add(passphrase+string(digit), HISTORY_DIGITS)
+		}
+
+		return variants
+	}
+
+	add(prefix, HISTORY_DIGITS)
+
+	if number, err := strconv.Atoi(digits); err == nil {
+		for _, next := range []int{number - 1, number + 1} {
+			if next >= 0 {
+				add(prefix+fmt.Sprintf("%0*d", len(digits), next), HISTORY_DIGITS)
+			}
+		}
+	}
+
+	for digit := '0'; digit <= '9'; digit++ {
+		add(passphrase[:len(passphrase)-1]+string(digit), HISTORY_DIGITS)
+	}
+
+	return variants
+}
+
+/*
+Look up  the password and its mutations  in the history, returns the
+kind of the first match or an empty string. The password itself is
+compared to all entries first, then its mutations to the newest entries.
+Comparisons  which would exceed  HISTORY_MAX_WORK iterations in total
+are skipped, so with many or expensive entries older ones are only
+checked for the exact password or not at all.
+*/
+func getHistoryMatch(passphrase string, store HistoryStore) (string, error) {
+	entries, err := store.Entries()
+	if err != nil {
+		return "", fmt.Errorf("history lookup failed: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	entries = lastEntries(entries, HISTORY_MAX_ENTRIES)
+	variants := getHistoryVariants(passphrase)
+	budget := HISTORY_MAX_WORK
+
+	matches := func(entry HistoryEntry, variant historyVariant) bool {
+		if !entry.valid() || entry.Iterations > budget {
+			return false
+		}
+
+		budget -= entry.Iterations
+
+		return entry.Matches(variant.word)
+	}
+
+	for id := len(entries) - 1; id >= 0; id-- {
+		if matches(entries[id], variants[0]) {
+			return variants[0].kind, nil
+		}
+	}
+
+	for id := len(entries) - 1; id >= 0; id-- {
+		for _, variant := range variants[1:] {
+			if matches(entries[id], variant) {
+				return variant.kind, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+/*
+PBKDF2 with HMAC-SHA256 as described in RFC 8018. Implemented here to
+keep valpass free of external dependencies.
+*/
+func pbkdf2SHA256(password, salt []byte, iterations, keylen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashlen := prf.Size()
+	blocks := (keylen + hashlen - 1) / hashlen
+
+	key := make([]byte, 0, blocks*hashlen)
+	counter := make([]byte, 4)
+	u := make([]byte, hashlen)
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Write(counter)
+
+		key = prf.Sum(key)
+		t := key[len(key)-hashlen:]
+		copy(u, t)
+
+		for i := 2; i <= iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+
+	return key[:keylen]
+}
@@ -0,0 +1,300 @@
+package valpass_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var pass_history = []string{`Summer2023`, `CorrectHorse7`, `Tr0ub4dor&3`}
+
+// fast hashing, we don't need to be slow in tests
+const history_iterations = 100
+
+type failingHistory struct{}
+
+func (failingHistory) Add(string) error { return nil }
+
+func (failingHistory) Entries() ([]valpass.HistoryEntry, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func CheckHistory(t *testing.T, store valpass.HistoryStore) {
+	for _, pass := range pass_history {
+		if err := store.Add(pass); err != nil {
+			t.Fatalf("failed to add password to history: %s", err)
+		}
+	}
+
+	var historytests = []struct {
+		pass string
+		kind string
+	}{
+		{`Summer2023`, valpass.HISTORY_EXACT},
+		{`Summer2024`, valpass.HISTORY_DIGITS},
+		{`Summer2022`, valpass.HISTORY_DIGITS},
+		{`summer2023`, valpass.HISTORY_CASE},
+		{`cORRECThORSE7`, valpass.HISTORY_CASE},
+		{`CorrectHorse8`, valpass.HISTORY_DIGITS},
+		{`CorrectHorse`, valpass.HISTORY_DIGITS},
+		{`CorrectHorses`, ""},
+		{`Tr0ub4dor&3`, valpass.HISTORY_EXACT},
+		{`Tr0ub4dor&4`, valpass.HISTORY_DIGITS},
+		{`Winter2023`, ""},
+	}
+
+	opts := valpass.Options{History: store}
+
+	for _, tt := range historytests {
+		result, err := valpass.Validate(tt.pass, opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.ReusedKind != tt.kind || result.Reused != (tt.kind != "") {
+			t.Errorf("unexpected history match for %s. want: %q, got: %q", tt.pass, tt.kind, result.ReusedKind)
+		}
+
+		if tt.kind != "" && result.Failures[0].Check != valpass.CHECK_HISTORY {
+			t.Errorf("unexpected failure: %v", result.Failures)
+		}
+	}
+}
+
+func TestMemoryHistory(t *testing.T) {
+	t.Parallel()
+
+	store := valpass.NewMemoryHistory(0)
+	store.Iterations = history_iterations
+
+	CheckHistory(t, store)
+
+	// only the last entries are kept
+	small := valpass.NewMemoryHistory(2)
+	small.Iterations = history_iterations
+
+	for _, pass := range pass_history {
+		if err := small.Add(pass); err != nil {
+			t.Fatalf("failed to add password to history: %s", err)
+		}
+	}
+
+	entries, err := small.Entries()
+	if err != nil {
+		t.Fatalf("failed to get history entries: %s", err)
+	}
+
+	if len(entries) != 2 || entries[0].Matches(pass_history[0]) || !entries[1].Matches(pass_history[2]) {
+		t.Errorf("history not limited to the last 2 entries")
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history")
+
+	store := valpass.NewFileHistory(path, 0)
+	store.Iterations = history_iterations
+
+	// missing file, empty history
+	entries, err := store.Entries()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("unexpected entries in missing history: %v, %v", entries, err)
+	}
+
+	CheckHistory(t, store)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read history file: %s", err)
+	}
+
+	for _, pass := range pass_history {
+		if strings.Contains(string(content), pass) {
+			t.Errorf("history file contains plain text password %s", pass)
+		}
+	}
+
+	// a new store on the same file sees the entries
+	entries, err = valpass.NewFileHistory(path, 0).Entries()
+	if err != nil || len(entries) != len(pass_history) {
+		t.Errorf("unexpected entries in history file: %v, %v", entries, err)
+	}
+
+	if err := os.WriteFile(path, []byte("md5:1:00:00\n"), 0600); err != nil {
+		t.Fatalf("failed to write history file: %s", err)
+	}
+
+	if _, err := valpass.Validate(`Summer2023`, valpass.Options{History: store}); err == nil {
+		t.Errorf("corrupt history file has not been detected")
+	}
+
+	// a tampered iteration count would make every lookup slow
+	if err := os.WriteFile(path, []byte("pbkdf2-sha256:1000000000:00:00\n"), 0600); err != nil {
+		t.Fatalf("failed to write history file: %s", err)
+	}
+
+	if _, err := store.Entries(); err == nil {
+		t.Errorf("excessive iterations in history file have not been detected")
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := valpass.NewHistoryEntry(`Summer2023`, valpass.HISTORY_MAX_ITERATIONS+1); err == nil {
+		t.Errorf("excessive iterations have not been rejected")
+	}
+
+	if _, err := valpass.Validate(`Summer2023`, valpass.Options{History: failingHistory{}}); err == nil {
+		t.Errorf("history store errors are not returned")
+	}
+}
+
+type customHistory []valpass.HistoryEntry
+
+func (history *customHistory) Add(passphrase string) error {
+	entry, err := valpass.NewHistoryEntry(passphrase, history_iterations)
+	if err != nil {
+		return err
+	}
+
+	*history = append(*history, entry)
+
+	return nil
+}
+
+func (history *customHistory) Entries() ([]valpass.HistoryEntry, error) {
+	return *history, nil
+}
+
+func TestHistoryInvalidEntries(t *testing.T) {
+	t.Parallel()
+
+	entry, err := valpass.NewHistoryEntry(`Summer2023`, history_iterations)
+	if err != nil {
+		t.Fatalf("failed to create history entry: %s", err)
+	}
+
+	// broken entries of custom stores must not match every password
+	store := customHistory{
+		{},
+		{Salt: entry.Salt, Iterations: history_iterations},
+		{Salt: entry.Salt, Hash: entry.Hash[:8], Iterations: history_iterations},
+		{Salt: entry.Salt, Hash: entry.Hash, Iterations: -1},
+		{Salt: entry.Salt, Hash: entry.Hash, Iterations: valpass.HISTORY_MAX_ITERATIONS + 1},
+	}
+
+	for _, invalid := range store {
+		if invalid.Matches(`Summer2023`) {
+			t.Errorf("invalid history entry %+v matches", invalid)
+		}
+	}
+
+	result, err := valpass.Validate(`Winter2023`, valpass.Options{History: &store})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Reused {
+		t.Errorf("invalid history entries flagged the password as reused")
+	}
+}
+
+func TestHistoryMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	store := customHistory{}
+
+	for _, pass := range pass_random_good[:valpass.HISTORY_MAX_ENTRIES+1] {
+		if err := store.Add(pass); err != nil {
+			t.Fatalf("failed to add password to history: %s", err)
+		}
+	}
+
+	// the oldest entry is beyond the checked ones
+	result, err := valpass.Validate(pass_random_good[0], valpass.Options{History: &store})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Reused {
+		t.Errorf("more than %d history entries have been checked", valpass.HISTORY_MAX_ENTRIES)
+	}
+
+	result, err = valpass.Validate(pass_random_good[1], valpass.Options{History: &store})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !result.Reused {
+		t.Errorf("recent history entry has not been found")
+	}
+}
+
+func TestHistoryMaxWork(t *testing.T) {
+	t.Parallel()
+
+	// only this many of the expensive entries fit into the budget
+	checked := valpass.HISTORY_MAX_WORK / valpass.HISTORY_MAX_ITERATIONS
+	store := customHistory{}
+
+	for i := 0; i <= checked; i++ {
+		entry, err := valpass.NewHistoryEntry(fmt.Sprintf("Summer%d", i), valpass.HISTORY_MAX_ITERATIONS)
+		if err != nil {
+			t.Fatalf("failed to create history entry: %s", err)
+		}
+
+		store = append(store, entry)
+	}
+
+	var worktests = []struct {
+		pass   string
+		reused bool
+	}{
+		// the oldest entry is beyond the budget
+		{`Summer0`, false},
+		{fmt.Sprintf("Summer%d", checked), true},
+		// the exact lookups used up the budget, no mutations are checked
+		{fmt.Sprintf("summer%d", checked), false},
+	}
+
+	for _, tt := range worktests {
+		result, err := valpass.Validate(tt.pass, valpass.Options{History: &store})
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Reused != tt.reused {
+			t.Errorf("unexpected history match for %s. want: %t, got: %q", tt.pass, tt.reused, result.ReusedKind)
+		}
+	}
+}
+
+func TestHistoryDefaults(t *testing.T) {
+	t.Parallel()
+
+	// a full history with the default iterations is checked completely
+	store := valpass.NewMemoryHistory(0)
+
+	for _, pass := range append([]string{`Xk7#pQ2v!mZ3`}, pass_random_good[:valpass.HISTORY_SIZE-1]...) {
+		if err := store.Add(pass); err != nil {
+			t.Fatalf("failed to add password to history: %s", err)
+		}
+	}
+
+	result, err := valpass.Validate(`Xk7#pQ2v!mZ4`, valpass.Options{History: store})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.ReusedKind != valpass.HISTORY_DIGITS {
+		t.Errorf("variant of the oldest history entry not found: %q", result.ReusedKind)
+	}
+}
@@ -0,0 +1,396 @@
+package valpass
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyboardLayout describes the physical layout of a keyboard, which is
+// used to detect keyboard walks like "qwerty" or "1qaz2wsx".
+type KeyboardLayout struct {
+	// Name of the layout, used to register it and in KeyboardWalk.
+	Name string
+
+	// Rows contains the keys  of each row, separated by white space.
+	// A key  lists the character it  produces, followed by the one(s)
+	// it produces with shift, e.g. "qQ" or "1!".
+	Rows []string
+
+	// Offsets contains the horizontal position  of the first key of
+	// each row, counted in keys. Missing offsets are 0.
+	Offsets []int
+
+	// Slanted is true  for keyboards with staggered rows  and false
+	// for grid aligned keypads.
+	Slanted bool
+}
+
+// KeyboardWalk describes a keyboard walk found in a password.
+type KeyboardWalk struct {
+	Layout  string // name of the keyboard layout
+	Token   string // the part of the password forming the walk
+	Start   int    // position of the first character, counted in characters
+	Length  int    // number of keys
+	Turns   int    // number of direction changes
+	Shifted int    // number of shifted characters
+}
+
+const (
+	MIN_KEYBOARD_WALK int = 4
+
+	// walks shorter than this are not being reported at all
+	keyboard_min_walk int = 3
+
+	// zig zag walks like "1q2w3e" must be at least this long
+	keyboard_min_zigzag int = 6
+)
+
+// The layouts shipped with valpass.
+var (
+	LAYOUT_QWERTY = KeyboardLayout{
+		Name: "qwerty",
+		Rows: []string{
+			"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+",
+			"qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|",
+			"aA sS dD fF gG hH jJ kK lL ;: '\"",
+			"zZ xX cC vV bB nN mM ,< .> /?",
+		},
+		Offsets: []int{0, 1, 1, 1},
+		Slanted: true,
+	}
+
+	LAYOUT_QWERTZ = KeyboardLayout{
+		Name: "qwertz",
+		Rows: []string{
+			"^° 1! 2\" 3§ 4$ 5% 6& 7/ 8( 9) 0= ß? ´`",
+			"qQ wW eE rR tT zZ uU iI oO pP üÜ +*",
+			"aA sS dD fF gG hH jJ kK lL öÖ äÄ #'",
+			"<> yY xX cC vV bB nN mM ,; .: -_",
+		},
+		Offsets: []int{0, 1, 1, 1},
+		Slanted: true,
+	}
+
+	LAYOUT_AZERTY = KeyboardLayout{
+		Name: "azerty",
+		Rows: []string{
+			"² &1 é2 \"3 '4 (5 -6 è7 _8 ç9 à0 )° =+",
+			"aA zZ eE rR tT yY uU iI oO pP ^¨ $£",
+			"qQ sS dD fF gG hH jJ kK lL mM ù% *µ",
+			"<> wW xX cC vV bB nN ,? ;. :/ !§",
+		},
+		Offsets: []int{0, 1, 1, 1},
+		Slanted: true,
+	}
+
+	LAYOUT_KEYPAD = KeyboardLayout{
+		Name: "keypad",
+		Rows: []string{
+			"/ * -",
+			"7 8 9 +",
+			"4 5 6",
+			"1 2 3",
+			"0 .",
+		},
+		Offsets: []int{1, 0, 0, 0, 1},
+		Slanted: false,
+	}
+)
+
+// Directions to neighbouring keys. On staggered keyboards the rows are
+// shifted by half a key, so every key has 6 neighbours.
+var (
+	slanted_neighbours = [][2]int{{-1, 0}, {0, -1}, {1, -1}, {1, 0}, {0, 1}, {-1, 1}}
+	aligned_neighbours = [][2]int{{-1, 0}, {-1, -1}, {0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}}
+)
+
+// keyPosition is the location of a character on a keyboard.
+type keyPosition struct {
+	x, y    int
+	shifted bool
+}
+
+// keyboardGraph is the compiled adjacency graph of a layout.
+type keyboardGraph struct {
+	name       string
+	positions  map[rune]keyPosition
+	neighbours [][2]int
+}
+
+var (
+	keyboardMutex  sync.RWMutex
+	keyboardGraphs = map[string]*keyboardGraph{}
+)
+
+func init() {
+	for _, layout := range []KeyboardLayout{LAYOUT_QWERTY, LAYOUT_QWERTZ, LAYOUT_AZERTY, LAYOUT_KEYPAD} {
+		if err := RegisterKeyboardLayout(layout); err != nil {
+			panic(err)
+		}
+	}
+}
+
+/*
+RegisterKeyboardLayout adds a custom keyboard layout, which will then
+be used for keyboard walk detection. A layout with the same name will
+be replaced.
+*/
+func RegisterKeyboardLayout(layout KeyboardLayout) error {
+	if layout.Name == "" {
+		return fmt.Errorf("keyboard layout has no name")
+	}
+
+	if len(layout.Rows) == 0 {
+		return fmt.Errorf("keyboard layout %s has no rows", layout.Name)
+	}
+
+	if len(layout.Offsets) > len(layout.Rows) {
+		return fmt.Errorf("keyboard layout %s has more offsets than rows", layout.Name)
+	}
+
+	graph := &keyboardGraph{
+		name:       layout.Name,
+		positions:  map[rune]keyPosition{},
+		neighbours: aligned_neighbours,
+	}
+
+	if layout.Slanted {
+		graph.neighbours = slanted_neighbours
+	}
+
+	for y, row := range layout.Rows {
+		offset := 0
+		if y < len(layout.Offsets) {
+			offset = layout.Offsets[y]
+		}
+
+		for x, key := range strings.Fields(row) {
+			for i, char := range []rune(key) {
+				// keep the first position of chars occurring on multiple keys
+				if _, exists := graph.positions[char]; !exists {
+					graph.positions[char] = keyPosition{x: offset + x, y: y, shifted: i > 0}
+				}
+			}
+		}
+	}
+
+	keyboardMutex.Lock()
+	defer keyboardMutex.Unlock()
+
+	keyboardGraphs[layout.Name] = graph
+
+	return nil
+}
+
+// KeyboardLayouts returns the names of all registered keyboard layouts.
+func KeyboardLayouts() []string {
+	keyboardMutex.RLock()
+	defer keyboardMutex.RUnlock()
+
+	names := make([]string, 0, len(keyboardGraphs))
+	for name := range keyboardGraphs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Returns the graphs of the given layouts, all registered if none given.
+func getKeyboardGraphs(names []string) ([]*keyboardGraph, error) {
+	if len(names) == 0 {
+		names = KeyboardLayouts()
+	}
+
+	keyboardMutex.RLock()
+	defer keyboardMutex.RUnlock()
+
+	graphs := make([]*keyboardGraph, 0, len(names))
+
+	for _, name := range names {
+		graph, ok := keyboardGraphs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown keyboard layout: %s", name)
+		}
+
+		graphs = append(graphs, graph)
+	}
+
+	return graphs, nil
+}
+
+// Returns the direction from one key to the other, -1 if they are not
+// adjacent.
+func (graph *keyboardGraph) direction(from, to rune) int {
+	a, oka := graph.positions[from]
+	b, okb := graph.positions[to]
+
+	if !oka || !okb {
+		return -1
+	}
+
+	for direction, offset := range graph.neighbours {
+		if a.x+offset[0] == b.x && a.y+offset[1] == b.y {
+			return direction
+		}
+	}
+
+	return -1
+}
+
+// Returns true if both chars are on the same key.
+func (graph *keyboardGraph) sameKey(a, b rune) bool {
+	return graph.positions[a].x == graph.positions[b].x &&
+		graph.positions[a].y == graph.positions[b].y
+}
+
+// Find all walks of at least keyboard_min_walk keys in the password.
+func (graph *keyboardGraph) walks(passphrase string) []KeyboardWalk {
+	chars := []rune(passphrase)
+	walks := []KeyboardWalk{}
+
+	for start := 0; start < len(chars)-1; {
+		end := start + 1
+		directions := []int{}
+
+		for end < len(chars) {
+			direction := graph.direction(chars[end-1], chars[end])
+			if direction == -1 {
+				break
+			}
+
+			// going back and forth between two keys is no walk
+			if end-start > 1 && graph.sameKey(chars[end], chars[end-2]) {
+				break
+			}
+
+			directions = append(directions, direction)
+			end++
+		}
+
+		if walk, ok := graph.newWalk(chars, start, end, directions); ok {
+			walks = append(walks, walk)
+		}
+
+		// the walk broke between end-1 and end, so continue at end
+		start = end
+	}
+
+	return walks
+}
+
+/*
+Create the walk  from start to end,  if it looks like  one. Walks with
+lots of turns are mostly random, so  they only count if there are less
+turns than half of the keys or  if they are a regular zig zag like
+"1q2w3e4r".
+*/
+func (graph *keyboardGraph) newWalk(chars []rune, start, end int, directions []int) (KeyboardWalk, bool) {
+	length := end - start
+	if length < keyboard_min_walk {
+		return KeyboardWalk{}, false
+	}
+
+	turns := 0
+	zigzag := len(directions) > 1
+
+	for i := 1; i < len(directions); i++ {
+		if directions[i] != directions[i-1] {
+			turns++
+		}
+
+		if i > 1 && directions[i] != directions[i-2] {
+			zigzag = false
+		}
+	}
+
+	if turns*2 >= length && !(zigzag && length >= keyboard_min_zigzag) {
+		return KeyboardWalk{}, false
+	}
+
+	walk := KeyboardWalk{
+		Layout: graph.name,
+		Token:  string(chars[start:end]),
+		Start:  start,
+		Length: length,
+		Turns:  turns,
+	}
+
+	for _, char := range chars[start:end] {
+		if graph.positions[char].shifted {
+			walk.Shifted++
+		}
+	}
+
+	return walk, true
+}
+
+/*
+Return the keyboard walks in the password using the given layouts.
+Walks which are part of a walk found on another layout are skipped,
+so "asdf" is only reported once, although it's a walk on qwerty and
+qwertz.
+*/
+func getKeyboardWalks(passphrase string, layouts []string) ([]KeyboardWalk, error) {
+	graphs, err := getKeyboardGraphs(layouts)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []KeyboardWalk{}
+	for _, graph := range graphs {
+		found = append(found, graph.walks(passphrase)...)
+	}
+
+	// longest first, then by position and layout, so the result is stable
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Length != found[j].Length {
+			return found[i].Length > found[j].Length
+		}
+
+		if found[i].Start != found[j].Start {
+			return found[i].Start < found[j].Start
+		}
+
+		return found[i].Layout < found[j].Layout
+	})
+
+	walks := []KeyboardWalk{}
+
+	for _, walk := range found {
+		contained := false
+
+		for _, longer := range walks {
+			if walk.Start >= longer.Start && walk.Start+walk.Length <= longer.Start+longer.Length {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			walks = append(walks, walk)
+		}
+	}
+
+	sort.SliceStable(walks, func(i, j int) bool {
+		return walks[i].Start < walks[j].Start
+	})
+
+	return walks, nil
+}
+
+// Returns the longest walk, the zero walk if there are none.
+func longestWalk(walks []KeyboardWalk) KeyboardWalk {
+	var longest KeyboardWalk
+
+	for _, walk := range walks {
+		if walk.Length > longest.Length {
+			longest = walk
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,110 @@
+package valpass_test
+
+import (
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var pass_keyboard_bad = []string{
+	`qwertz123`, `1qaz2wsx`, `asdfghjkl;`, `zxcvbnm`, `QWERTY`,
+	`azerty`, `!QAZ@WSX`, `poiuytrewq`, `qwerasdf`, `7894561230`,
+	`xsw2zaq1`, `mnbvcxy`, `wxcvbn`, `zaq1zaq1`, `1q2w3e4r`,
+}
+
+var opts_keyboard = valpass.Options{
+	KeyboardWalk: valpass.MIN_KEYBOARD_WALK,
+}
+
+func TestKeyboardWalks(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range pass_keyboard_bad {
+		CheckPassword(t, pass, Test{name: "checkbad-keyboard", want: false, opts: opts_keyboard})
+	}
+
+	for _, pass := range append(pass_random_good, pass_diceware_good...) {
+		CheckPassword(t, pass, Test{name: "checkgood-keyboard", want: true, opts: opts_keyboard})
+	}
+}
+
+func TestKeyboardWalkResult(t *testing.T) {
+	t.Parallel()
+
+	var walktests = []struct {
+		pass  string
+		walks []valpass.KeyboardWalk
+	}{
+		{`1qaz2wsx`, []valpass.KeyboardWalk{
+			{Layout: "qwerty", Token: "1qaz", Start: 0, Length: 4, Turns: 0, Shifted: 0},
+			{Layout: "qwerty", Token: "2wsx", Start: 4, Length: 4, Turns: 0, Shifted: 0},
+		}},
+		{`x!QAZ@`, []valpass.KeyboardWalk{
+			{Layout: "qwerty", Token: "!QAZ", Start: 1, Length: 4, Turns: 0, Shifted: 4},
+		}},
+		{`qwerdcxz`, []valpass.KeyboardWalk{
+			{Layout: "qwerty", Token: "qwerdcxz", Start: 0, Length: 8, Turns: 3, Shifted: 0},
+		}},
+	}
+
+	for _, tt := range walktests {
+		result, err := valpass.Validate(tt.pass, valpass.Options{
+			KeyboardWalk:    valpass.MIN_KEYBOARD_WALK,
+			KeyboardLayouts: []string{"qwerty"},
+		})
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if len(result.KeyboardWalks) != len(tt.walks) {
+			t.Fatalf("unexpected walks in %s. want: %v, got: %v", tt.pass, tt.walks, result.KeyboardWalks)
+		}
+
+		for i, walk := range result.KeyboardWalks {
+			want := tt.walks[i]
+
+			if walk != want {
+				t.Errorf("unexpected walk in %s. want: %v, got: %v", tt.pass, want, walk)
+			}
+		}
+	}
+}
+
+func TestKeyboardCustomLayout(t *testing.T) {
+	t.Parallel()
+
+	// a made up layout, so no other test is affected
+	layout := valpass.KeyboardLayout{
+		Name:    "test-abc",
+		Rows:    []string{"ğ ĥ ĵ ķ", "ĺ ń ŗ ś"},
+		Slanted: false,
+	}
+
+	if err := valpass.RegisterKeyboardLayout(layout); err != nil {
+		t.Fatalf("failed to register layout: %s", err)
+	}
+
+	result, err := valpass.Validate(`xğĥĵśx`, valpass.Options{
+		KeyboardWalk:    4,
+		KeyboardLayouts: []string{"test-abc"},
+	})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Ok || len(result.KeyboardWalks) != 1 || result.KeyboardWalks[0].Token != "ğĥĵś" {
+		t.Errorf("walk on custom layout not detected: %v", result)
+	}
+
+	if err := valpass.RegisterKeyboardLayout(valpass.KeyboardLayout{Name: "empty"}); err == nil {
+		t.Errorf("registering an empty layout did not fail")
+	}
+
+	_, err = valpass.Validate(`qwerty`, valpass.Options{
+		KeyboardWalk:    4,
+		KeyboardLayouts: []string{"does-not-exist"},
+	})
+	if err == nil {
+		t.Errorf("using an unknown layout did not fail")
+	}
+}
@@ -0,0 +1,161 @@
+package valpass
+
+import (
+	"strings"
+)
+
+// LEET_TABLE contains the default l33t substitutions, it maps a
+// character to the letters it might stand for.
+var LEET_TABLE = map[rune][]rune{
+	'0': {'o'},
+	'1': {'l', 'i'},
+	'2': {'z'},
+	'3': {'e'},
+	'4': {'a'},
+	'5': {'s'},
+	'6': {'g'},
+	'7': {'t'},
+	'8': {'b'},
+	'9': {'g'},
+	'@': {'a'},
+	'$': {'s'},
+	'!': {'i', 'l'},
+	'|': {'l', 'i'},
+	'+': {'t'},
+	'(': {'c'},
+	'<': {'c'},
+	'%': {'x'},
+}
+
+// maximum number of l33t candidates generated per password
+const LEET_MAX_CANDIDATES int = 256
+
+// maximum number of fully substituted candidates, see getFullLeetCandidates()
+const leet_max_full int = 4
+
+// leetCandidate is a password with l33t substitutions undone.
+type leetCandidate struct {
+	word          string
+	substitutions map[string]string
+}
+
+/*
+Return all plausible  de-l33ted versions of the  lower cased password.
+Like in most l33t speak, a character is assumed to stand for the same
+letter across the whole password, so "1" is either replaced by "l" or
+by "i" everywhere (or not at all).
+*/
+func getLeetCandidates(lcpass string, table map[rune][]rune) []leetCandidate {
+	if table == nil {
+		table = LEET_TABLE
+	}
+
+	// substitutable chars in order of appearance
+	chars := []rune{}
+	seen := map[rune]bool{}
+
+	for _, char := range lcpass {
+		if _, ok := table[char]; ok && !seen[char] {
+			seen[char] = true
+			chars = append(chars, char)
+		}
+	}
+
+	if len(chars) == 0 {
+		return nil
+	}
+
+	// count through all combinations, choice 0 means keep the char
+	choices := make([]int, len(chars))
+	candidates := []leetCandidate{}
+
+	for len(candidates) < LEET_MAX_CANDIDATES {
+		// increment the mixed radix counter
+		pos := 0
+		for ; pos < len(chars); pos++ {
+			choices[pos]++
+			if choices[pos] <= len(table[chars[pos]]) {
+				break
+			}
+
+			choices[pos] = 0
+		}
+
+		if pos == len(chars) {
+			// overflow, we've seen every combination
+			break
+		}
+
+		mapping := map[rune]rune{}
+		substitutions := map[string]string{}
+
+		for i, char := range chars {
+			if choices[i] > 0 {
+				replacement := table[char][choices[i]-1]
+				mapping[char] = replacement
+				substitutions[string(char)] = string(replacement)
+			}
+		}
+
+		candidates = append(candidates, leetCandidate{word: replaceRunes(lcpass, mapping), substitutions: substitutions})
+	}
+
+	return candidates
+}
+
+/*
+Return the de-l33ted versions of the  lower cased password in which all
+substitutable chars are replaced: the first  one uses the first letter
+of every char, the second one the second letter, where there is one,
+and so on, at most leet_max_full. Unlike getLeetCandidates() this is a
+small set, used for lookups too expensive to run on every candidate.
+*/
+func getFullLeetCandidates(lcpass string, table map[rune][]rune) []leetCandidate {
+	if table == nil {
+		table = LEET_TABLE
+	}
+
+	candidates := []leetCandidate{}
+
+	for choice := 0; choice < leet_max_full; choice++ {
+		mapping := map[rune]rune{}
+		substitutions := map[string]string{}
+		more := false
+
+		for _, char := range lcpass {
+			letters, ok := table[char]
+			if !ok || len(letters) == 0 {
+				continue
+			}
+
+			replacement := letters[Min(choice, len(letters)-1)]
+			mapping[char] = replacement
+			substitutions[string(char)] = string(replacement)
+			more = more || choice < len(letters)-1
+		}
+
+		if len(mapping) == 0 {
+			break
+		}
+
+		candidates = append(candidates, leetCandidate{word: replaceRunes(lcpass, mapping), substitutions: substitutions})
+
+		if !more {
+			// every char used its last letter
+			break
+		}
+	}
+
+	return candidates
+}
+
+// Replace chars according to the mapping.
+func replaceRunes(text string, mapping map[rune]rune) string {
+	return strings.Map(func(char rune) rune {
+		if replacement, ok := mapping[char]; ok {
+			return replacement
+		}
+
+		return char
+	}, text)
+}
@@ -47,6 +47,12 @@ func NewLevenshtein() *Levenshtein {
 // closer matches.
 func (m *Levenshtein) Compare(a, b string) float64 {
 	distance, maxLen := m.distance(a, b)
+	return similarity(distance, maxLen)
+}
+
+// similarity converts a distance between terms of at most maxLen
+// characters into the similarity returned by Compare.
+func similarity(distance, maxLen int) float64 {
 	return 1 - float64(distance)/float64(maxLen)
 }
 
@@ -2,11 +2,12 @@
 package valpass
 
 import (
-	"bytes"
-	"compress/flate"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Dictionary is a container struct to store and submit a dictionary of words.
@@ -14,6 +15,19 @@ type Dictionary struct {
 	Words    []string // Contains the actual dictionary.
 	Submatch bool     // Set to true to enable submatches, e.g. 'foo' would match 'foobar', default is false.
 	Fuzzy    bool     // Set to true to enable more lax dictionary checks, default is false.
+
+	// Tunables for  fuzzy matching, only used if Fuzzy  is true. A
+	// password  is flagged  if  it is  within  MaxDistance edits  OR
+	// reaches MinSimilarity (0..1) to  any dictionary word. Set one
+	// of them to zero to disable it, if both are zero the defaults
+	// MAX_FUZZY_DISTANCE and MIN_FUZZY_SIMILARITY are being used.
+	MaxDistance   int
+	MinSimilarity float64
+
+	Leet      bool            // Set to true to undo l33t substitutions like "p4ssw0rd" before lookups.
+	LeetTable map[rune][]rune // l33t substitutions to undo, default LEET_TABLE
+
+	index *dictIndex // lookup indices, see Compile()
 }
 
 // Options struct can be used  to configure the validator, turn on/off
@@ -22,10 +36,42 @@ type Dictionary struct {
 //
 // Set option to zero or false to disable the feature.
 type Options struct {
-	Compress         int         // minimum compression rate in percent, default 10%
-	CharDistribution float64     // minimum character distribution in percent, default 10%
-	Entropy          float64     // minimum entropy value in bits/char, default 3 bits/s
-	Dictionary       *Dictionary // lookup given dictionary, the caller has to provide it
+	Compress         int           // minimum compression rate in percent, default 10%
+	Compressors      []Compressor  // compression backends for Compress, the highest rate counts, default a FlateCompressor
+	Explained        int           // flag passwords explained by dictionary words and user inputs by this many percent, e.g. MAX_EXPLAINED
+	CharDistribution float64       // minimum character distribution in percent, default 10%
+	Entropy          float64       // minimum entropy value in bits/char, default 3 bits/s
+	TotalEntropy     float64       // minimum entropy of the whole password in bits, Entropy times length
+	PoolEntropy      float64       // minimum entropy in bits estimated from length and character classes used
+	AlphabetSize     int           // number of possible chars for CharDistribution, default MAX_CHARS
+	Dictionary       *Dictionary   // lookup given dictionary, the caller has to provide it
+	BreachFile       string        // lookup password in a sorted HIBP "SHA1:count" file
+	Breach           BreachChecker // lookup password using a breach checker, e.g. a RangeClient
+	BreachMinCount   int           // minimum breach count to flag the password, default 1
+	MinLength        int           // minimum password length in characters
+	MaxLength        int           // maximum password length in characters
+	RequireUpper     bool          // password must contain an upper case letter
+	RequireLower     bool          // password must contain a lower case letter
+	RequireDigit     bool          // password must contain a digit
+	RequireSymbol    bool          // password must contain a special character
+	MinClasses       int           // minimum number of the above character classes used, 1-4
+	KeyboardWalk     int           // flag keyboard walks of at least this many keys, e.g. MIN_KEYBOARD_WALK
+	KeyboardLayouts  []string      // keyboard layouts to check for walks, default all registered
+	Sequence         int           // flag sequences and repetitions of at least this many chars, e.g. MIN_SEQUENCE
+	Dates            bool          // flag dates and years, e.g. birthdays
+	MinYear          int           // years before this are not considered, default DATE_MIN_YEAR
+	MaxYear          int           // years after this are not considered, default DATE_MAX_YEAR
+	GuessesLog10     float64       // minimum number of guesses needed to crack the password as log10, e.g. MIN_GUESSES_LOG10
+	ScoreCutoffs     *ScoreCutoffs // how to calculate the score, default DEFAULT_SCORE_CUTOFFS
+	UserInputs       []string      // user specific data like user name, email or company the password must not be based on
+	History          HistoryStore  // previous passwords which must not be reused, e.g. a MemoryHistory
+	Checks           []Check       // custom checks, executed in order after the built-in ones
+	Builtin          []Check       // replaces the built-in checks, e.g. a reordered BuiltinChecks(), nil derives them from the options
+
+	Passphrase           bool    // detect passphrases and check them by word instead of Entropy and CharDistribution, needs a Dictionary
+	PassphraseWords      int     // minimum number of words of a passphrase, default PASSPHRASE_WORDS
+	PassphraseWordLength int     // minimum length of every word of a passphrase, default PASSPHRASE_WORD_LENGTH
+	PassphraseEntropy    float64 // minimum word-level entropy of a passphrase in bits, default PASSPHRASE_ENTROPY
 }
 
 const (
@@ -33,212 +79,510 @@ const (
 	MIN_DIST     float64 = 10.0
 	MIN_ENTROPY  float64 = 3.0
 	MIN_DICT_LEN int     = 5000
-	MAX_CHARS    int     = 95 // maximum printable US ASCII chars
+	MAX_CHARS    int     = 95 // maximum printable US ASCII chars, default alphabet size
 
-	//  we start  our ascii  arrays  at char(32),  so to  have max  95
-	// elements in the slice, we subtract 32 from each ascii code
-	ascii_base byte = 32
+	MAX_FUZZY_DISTANCE   int     = 2
+	MIN_FUZZY_SIMILARITY float64 = 0.8
 )
 
 // Result stores the results of all validations.
 type Result struct {
-	Ok               bool    // overall result
-	DictionaryMatch  bool    // true if the password matched a dictionary entry
-	Compress         int     // actual compression rate in percent
-	CharDistribution float64 // actual character distribution in percent
-	Entropy          float64 // actual entropy value in bits/chars
+	Ok                   bool               // overall result
+	DictionaryMatch      bool               // true if the password matched a dictionary entry
+	DictionaryWord       string             // the (closest) dictionary word which matched
+	DictionaryDistance   int                // Levenshtein distance to DictionaryWord
+	DictionarySimilarity float64            // Levenshtein similarity to DictionaryWord, 0..1
+	DictionaryLeet       map[string]string  // l33t substitutions undone to find the match, e.g. "0" => "o"
+	UserInputMatches     []UserInputMatch   // user inputs found in the password
+	Compress             int                // actual compression rate in percent, the highest of all compressors
+	Compressions         map[string]int     // compression rate in percent per compressor, keyed by its name
+	Explained            int                // share of the password in percent explained by dictionary words and user inputs
+	CharDistribution     float64            // actual character distribution in percent
+	Entropy              float64            // actual entropy value in bits/chars
+	TotalEntropy         float64            // entropy of the whole password in bits
+	PoolEntropy          float64            // entropy in bits, length times log2 of the size of the classes used
+	Breached             bool               // true if the password is listed in a breach database
+	BreachCount          int                // how often the password has been seen in breaches
+	Reused               bool               // true if the password or a variant of it has been used before
+	ReusedKind           string             // how the password matched the history, one of the HISTORY_* constants
+	Length               int                // password length in characters
+	Classes              int                // number of character classes used, see Options.MinClasses
+	KeyboardWalks        []KeyboardWalk     // keyboard walks found in the password
+	Sequences            []Sequence         // sequences and repetitions found in the password
+	Dates                []DateMatch        // dates and years found in the password
+	Guesses              float64            // estimated number of guesses needed to crack the password
+	GuessesLog10         float64            // the same as log10
+	CrackTimes           CrackTimes         // estimated times to crack the password
+	Score                int                // strength score, 0 (very weak) to 4 (very strong)
+	ScoreLabel           string             // label of the score, see SCORE_LABELS
+	Passphrase           bool               // true if the password has been detected as a passphrase, see Options.Passphrase
+	PassphraseWords      int                // number of words of the passphrase
+	PassphraseEntropy    float64            // word-level entropy of the passphrase in bits
+	Metrics              map[string]float64 // values measured by the checks, keyed by check name
+	Failures             []Failure          // why the password failed, one entry per failed check
 }
 
-// Validate  validates a given password.  You can  tune its  behavior
-// using the Options struct. However,  options are optional, there are
-// sensible defaults builtins.
-//
-// The returned Result struct returns the password quality.
-func Validate(passphrase string, opts ...Options) (Result, error) {
-	result := Result{Ok: true}
+// Failure describes why a password failed a single check.
+type Failure struct {
+	Check     string  // stable check identifier, one of the CHECK_* constants
+	Threshold float64 // the configured threshold
+	Value     float64 // the measured value
+	Message   string  // human readable explanation, suitable for end users
+}
 
-	// defaults, see above
-	options := Options{
+// Identifiers of the checks, used in Failure.Check.
+const (
+	CHECK_POLICY     string = "policy"
+	CHECK_ENTROPY    string = "entropy"
+	CHECK_TOTAL      string = "totalentropy"
+	CHECK_POOL       string = "poolentropy"
+	CHECK_COMPRESS   string = "compress"
+	CHECK_EXPLAINED  string = "explained"
+	CHECK_CHARDIST   string = "chardist"
+	CHECK_DICTIONARY string = "dictionary"
+	CHECK_BREACH     string = "breach"
+	CHECK_MINLENGTH  string = "minlength"
+	CHECK_MAXLENGTH  string = "maxlength"
+	CHECK_UPPER      string = "upper"
+	CHECK_LOWER      string = "lower"
+	CHECK_DIGIT      string = "digit"
+	CHECK_SYMBOL     string = "symbol"
+	CHECK_CLASSES    string = "classes"
+	CHECK_KEYBOARD   string = "keyboard"
+	CHECK_SEQUENCE   string = "sequence"
+	CHECK_DATE       string = "date"
+	CHECK_GUESSES    string = "guesses"
+	CHECK_USERINPUT  string = "userinput"
+	CHECK_HISTORY    string = "history"
+	CHECK_PASSPHRASE string = "passphrase"
+	CHECK_WORDS      string = "words"
+	CHECK_WORDLENGTH string = "wordlength"
+	CHECK_REPEATED   string = "repeated"
+)
+
+// Fail marks the result as failed and records why. Checks use it to
+// flag the password, see Check.
+func (result *Result) Fail(check string, threshold, value float64, message string) {
+	result.Ok = false
+	result.Failures = append(result.Failures, Failure{
+		Check:     check,
+		Threshold: threshold,
+		Value:     value,
+		Message:   message,
+	})
+}
+
+// DefaultOptions returns the options used by Validate() if none are
+// given: entropy, compression and character distribution with their
+// default thresholds.
+func DefaultOptions() Options {
+	return Options{
 		Compress:         MIN_COMPRESS,
 		CharDistribution: MIN_DIST,
 		Entropy:          MIN_ENTROPY,
 		Dictionary:       nil,
 	}
+}
 
-	if len(opts) == 1 {
+// Validate  validates a given password.  You can  tune its  behavior
+// using the Options struct. However,  options are optional, there are
+// sensible defaults builtins, see DefaultOptions().
+//
+// The returned Result struct returns the password quality.
+//
+// Validate  checks the options on every call. If you validate more
+// than one password, use a Validator instead.
+func Validate(passphrase string, opts ...Options) (Result, error) {
+	options := DefaultOptions()
+
+	switch len(opts) {
+	case 0:
+	case 1:
 		options = opts[0]
+	default:
+		return Result{}, fmt.Errorf("%w: expected one Options argument, got %d", ErrInvalidOptions, len(opts))
 	}
 
-	// execute the actual validation checks
+	if err := checkOptions(options); err != nil {
+		return Result{}, err
+	}
 
-	if options.Entropy > 0 {
-		var entropy float64
-		var err error
+	return newValidator(options).Validate(passphrase)
+}
 
-		entropy, err = getEntropy(passphrase)
-		if err != nil {
-			return result, err
-		}
+// Validate validates a given password using the options of the
+// Validator. It is safe for concurrent use.
+func (validator *Validator) Validate(passphrase string) (Result, error) {
+	// the score depends on the length, even if no PolicyCheck runs
+	result := Result{Ok: true, Length: utf8.RuneCountInString(passphrase)}
+	options := validator.options
+
+	// execute the actual validation checks
 
-		if entropy <= options.Entropy {
-			result.Ok = false
+	for _, checks := range [][]Check{validator.builtin, options.Checks} {
+		for _, check := range checks {
+			if err := check.Run(passphrase, &result); err != nil {
+				return result, err
+			}
 		}
+	}
+
+	getScore(&result, options)
 
-		result.Entropy = entropy
+	return result, nil
+}
+
+// SetMetric records a value measured by a check, see Check.
+func (result *Result) SetMetric(name string, value float64) {
+	if result.Metrics == nil {
+		result.Metrics = map[string]float64{}
 	}
 
-	if options.Compress > 0 {
-		compression, err := getCompression([]byte(passphrase))
-		if err != nil {
-			return result, err
-		}
+	result.Metrics[name] = value
+}
 
-		if compression >= options.Compress {
-			result.Ok = false
-		}
+/*
+ * we  compress the password, by default with  Flate level  9 (max), and
+ * see  if the  result is smaller than the password, in which case it
+ * could be compressed and contains repeating characters;  OR it is
+ * larger  than the password, in which case it could NOT be compressed,
+ * which is what we want.
+ */
+func getCompression(passphrase []byte, compressor Compressor) (int, error) {
+	size, err := compressor.CompressedSize(passphrase)
+	if err != nil {
+		return 0, err
+	}
 
-		result.Compress = compression
+	// use floats to avoid division by zero panic
+	length := float32(len(passphrase))
+	compressed := float32(size)
+
+	if compressed >= length {
+		return 0, nil
 	}
 
-	if options.CharDistribution > 0 {
-		var dist = getDistribution(passphrase)
+	percent := 100 - (compressed / (length / 100))
+
+	return int(percent), nil
+}
 
-		if dist <= options.CharDistribution {
-			result.Ok = false
+// ErrNonPrintable is returned  (wrapped) if the password contains
+// non-printable characters or is not valid UTF-8.
+var ErrNonPrintable = errors.New("non-printable character encountered")
+
+// Returns an error if a char is non-printable or if the password is
+// not valid UTF-8.
+func checkPrintable(passphrase string) error {
+	for _, char := range passphrase {
+		if char == utf8.RuneError || !unicode.IsPrint(char) {
+			return fmt.Errorf("%w: %q", ErrNonPrintable, char)
 		}
+	}
+
+	return nil
+}
+
+/*
+Return the entropy as bits/char, where char is a printable unicode
+character (rune). Returns error if a char is non-printable or if the
+password is not valid UTF-8.
+*/
+func getEntropy(passphrase string) (float64, error) {
+	var entropy float64
+	var length int
 
-		result.CharDistribution = dist
+	if err := checkPrintable(passphrase); err != nil {
+		return 0, err
 	}
 
-	if options.Dictionary != nil {
-		match, err := getDictMatch(passphrase, options.Dictionary)
-		if err != nil {
-			return result, err
-		}
+	hist := map[rune]int{}
 
-		if match {
-			result.Ok = false
-			result.DictionaryMatch = true
-		}
+	for _, char := range passphrase {
+		hist[char]++
+		length++
 	}
 
-	return result, nil
+	for _, count := range hist {
+		diff := float64(count) / float64(length)
+		entropy -= diff * math.Log2(diff)
+	}
+
+	return entropy, nil
 }
 
 /*
- * we  compress with  Flate level  9 (max)  and see  if the  result is
- * smaller than the password, in which case it could be compressed and
- * contains repeating characters;  OR it is larger  than the password,
- * in which case it could NOT be compressed, which is what we want.
- */
-func getCompression(passphrase []byte) (int, error) {
-	var b bytes.Buffer
-	flater, _ := flate.NewWriter(&b, 9)
+Return the pool based entropy in bits: length times log2 of the number
+of chars in the classes used, e.g. a password of lower case letters and
+digits draws from a pool of 36 chars. This is what a brute force attack
+knowing the classes would have to try.
+*/
+func getPoolEntropy(passphrase string) float64 {
+	classes := getClasses(passphrase)
+	pool := 0
 
-	if _, err := flater.Write(passphrase); err != nil {
-		return 0, fmt.Errorf("failed to write to flate writer: %w", err)
+	for index, used := range []bool{classes.upper, classes.lower, classes.digit, classes.symbol} {
+		if used {
+			pool += classPoolSizes[index]
+		}
 	}
 
-	if err := flater.Flush(); err != nil {
-		return 0, fmt.Errorf("failed to flush flate writer: %w", err)
+	if pool == 0 {
+		return 0
 	}
 
-	if err := flater.Close(); err != nil {
-		return 0, fmt.Errorf("failed to close flate writer: %w", err)
+	return float64(utf8.RuneCountInString(passphrase)) * math.Log2(float64(pool))
+}
+
+/*
+ * Return character distribution, that is the percentage of the alphabet
+ * used by the password. Alphabet size defaults to MAX_CHARS.
+ */
+func getDistribution(passphrase string, alphabet int) float64 {
+	if alphabet <= 0 {
+		alphabet = MAX_CHARS
 	}
 
-	// use floats to avoid division by zero panic
-	length := float32(len(passphrase))
-	compressed := float32(len(b.Bytes()))
+	hash := map[rune]bool{}
 
-	if compressed >= length {
-		return 0, nil
+	for _, char := range passphrase {
+		hash[char] = true
 	}
 
-	percent := 100 - (compressed / (length / 100))
+	chars := float64(len(hash))
+	if chars > float64(alphabet) {
+		// more different chars than the alphabet contains
+		return 100
+	}
 
-	return int(percent), nil
+	return chars / (float64(alphabet) / 100)
+}
+
+// dictMatch describes the dictionary word a password matched.
+type dictMatch struct {
+	found         bool
+	word          string
+	distance      int
+	similarity    float64
+	substitutions map[string]string // l33t substitutions undone
 }
 
 /*
-Return the entropy as bits/char, where  char is a printable char in
-US-ASCII space. Returns error if a char is non-printable.
-*/
-func getEntropy(passphrase string) (float64, error) {
-	var entropy float64
-	length := len(passphrase)
+ * Return a match if password can  be found in given dictionary. This
+ * has to be supplied by the user, we do NOT ship with a dictionary!
+ *
+ * Compiled dictionaries use their  indices, others are being scanned
+ * word by word.
+ */
+func getDictMatch(passphrase string, dict *Dictionary) (dictMatch, error) {
+	if len(dict.Words) < MIN_DICT_LEN {
+		return dictMatch{}, fmt.Errorf("provided dictionary is too small")
+	}
 
-	wherechar := make([]int, MAX_CHARS)
-	hist := make([]int, length)
-	var histlen int
+	candidates := []leetCandidate{{word: strings.ToLower(passphrase)}}
+	if dict.Leet {
+		candidates = append(candidates, getLeetCandidates(candidates[0].word, dict.LeetTable)...)
+	}
 
-	for i := 0; i < MAX_CHARS; i++ {
-		wherechar[i] = -1
+	if match := dict.lookup(candidates); match.found {
+		return match, nil
 	}
 
-	for _, char := range []byte(passphrase) {
-		if char < ascii_base || char > 126 {
-			return 0, fmt.Errorf("non-printable ASCII character encountered: %c", char)
-		}
-		if wherechar[char-ascii_base] == -1 {
-			wherechar[char-ascii_base] = histlen
-			histlen++
-		}
+	if !dict.Fuzzy {
+		return dictMatch{}, nil
+	}
 
-		hist[wherechar[char-ascii_base]]++
+	// every fuzzy lookup scans the dictionary, so only the password
+	// itself and the fully de-l33ted versions are looked up
+	fuzzy := []leetCandidate{candidates[0]}
+	if dict.Leet {
+		fuzzy = append(fuzzy, getFullLeetCandidates(candidates[0].word, dict.LeetTable)...)
 	}
 
-	for i := 0; i < histlen; i++ {
-		diff := float64(hist[i]) / float64(length)
-		entropy -= diff * math.Log2(diff)
+	best := dictMatch{}
+
+	for _, candidate := range fuzzy {
+		match := getFuzzyMatch(candidate.word, dict)
+
+		if match.found && (!best.found || match.distance < best.distance) {
+			match.substitutions = candidate.substitutions
+			best = match
+		}
 	}
 
-	return entropy, nil
+	return best, nil
 }
 
 /*
- * Return character distribution in US-ASCII space
- */
-func getDistribution(passphrase string) float64 {
-	hash := make([]int, MAX_CHARS)
-	var chars float64
+Lookup lower cased  password candidates, either exact  or as submatch.
+Earlier candidates are preferred, uncompiled dictionaries are scanned
+only once for all candidates.
+*/
+func (dict *Dictionary) lookup(candidates []leetCandidate) dictMatch {
+	if dict.index != nil {
+		for _, candidate := range candidates {
+			if match := dict.index.lookup(candidate.word, dict.Submatch); match.found {
+				match.substitutions = candidate.substitutions
+				return match
+			}
+		}
 
-	for _, char := range []byte(passphrase) {
-		hash[char-ascii_base]++
+		return dictMatch{}
 	}
 
-	for i := 0; i < MAX_CHARS; i++ {
-		if hash[i] > 0 {
-			chars++
+	best := -1
+	match := dictMatch{}
+
+	for _, word := range dict.Words {
+		lcword := strings.ToLower(word)
+
+		for id, candidate := range candidates {
+			if best != -1 && id >= best {
+				break
+			}
+
+			if (dict.Submatch && strings.Contains(lcword, candidate.word)) || lcword == candidate.word {
+				best = id
+				match = dictMatch{found: true, word: word, similarity: 1, substitutions: candidate.substitutions}
+			}
+		}
+
+		if best == 0 {
+			break
 		}
 	}
-	return chars / (float64(MAX_CHARS) / 100)
+
+	return match
+}
+
+// Returns the maximum distance used for fuzzy matches, 0 for exact ones.
+func (dict *Dictionary) fuzzyDistance() int {
+	switch {
+	case !dict.Fuzzy:
+		return 0
+	case dict.MaxDistance == 0 && dict.MinSimilarity == 0:
+		return MAX_FUZZY_DISTANCE
+	default:
+		return dict.MaxDistance
+	}
+}
+
+// Returns a human readable description of the match.
+func (match dictMatch) message() string {
+	if len(match.substitutions) > 0 {
+		return fmt.Sprintf("password is based on the dictionary word %q with some characters replaced", match.word)
+	}
+
+	if match.distance > 0 {
+		return fmt.Sprintf("password is too similar to the dictionary word %q", match.word)
+	}
+
+	return fmt.Sprintf("password is based on the dictionary word %q", match.word)
+}
+
+// fuzzyMatcher keeps track of the closest word seen so far.
+type fuzzyMatcher struct {
+	lcpass  string
+	passlen int
+	maxdist int
+	minsim  float64
+	metric  *Levenshtein
+	best    dictMatch
 }
 
 /*
- * Return true if password can be  found in given dictionary. This has
- * to be supplied by the user, we do NOT ship with a dictionary!
+ * Look for  the closest dictionary word  in terms of  the Levenshtein
+ * distance. Words which cannot satisfy  the thresholds because of the
+ * length difference alone are skipped without comparing them.
  */
-func getDictMatch(passphrase string, dict *Dictionary) (bool, error) {
-	if len(dict.Words) < MIN_DICT_LEN {
-		return false, fmt.Errorf("provided dictionary is too small")
+func getFuzzyMatch(lcpass string, dict *Dictionary) dictMatch {
+	fuzzy := fuzzyMatcher{
+		lcpass:  lcpass,
+		passlen: utf8.RuneCountInString(lcpass),
+		maxdist: dict.MaxDistance,
+		minsim:  dict.MinSimilarity,
+		metric:  NewLevenshtein(),
+		best:    dictMatch{distance: -1},
 	}
 
-	lcpass := strings.ToLower(passphrase)
+	if fuzzy.maxdist == 0 && fuzzy.minsim == 0 {
+		fuzzy.maxdist, fuzzy.minsim = MAX_FUZZY_DISTANCE, MIN_FUZZY_SIMILARITY
+	}
 
-	if dict.Submatch {
-		for _, word := range dict.Words {
-			if strings.Contains(strings.ToLower(word), lcpass) {
-				return true, nil
+	if dict.index != nil {
+		// only look at words whose length is close enough
+		for wordlen, ids := range dict.index.bylen {
+			if !fuzzy.possible(wordlen) {
+				continue
+			}
+
+			for _, id := range ids {
+				fuzzy.compare(dict.index.words[id], dict.index.lower[id], wordlen)
 			}
 		}
 	} else {
 		for _, word := range dict.Words {
-			if lcpass == strings.ToLower(word) {
-				return true, nil
+			wordlen := utf8.RuneCountInString(word)
+			if fuzzy.possible(wordlen) {
+				fuzzy.compare(word, strings.ToLower(word), wordlen)
 			}
 		}
 	}
 
-	return false, nil
+	if !fuzzy.best.found {
+		return dictMatch{}
+	}
+
+	return fuzzy.best
+}
+
+// The length difference is the lower bound of the distance, so we can
+// tell if a word of the given length can match at all.
+func (fuzzy *fuzzyMatcher) possible(wordlen int) bool {
+	lower := Max(fuzzy.passlen, wordlen) - Min(fuzzy.passlen, wordlen)
+
+	return fuzzyAcceptable(lower, Max(fuzzy.passlen, wordlen), fuzzy.maxdist, fuzzy.minsim)
+}
+
+// Compare the  password against a  word and remember  it, if it  is the
+// closest one so far.
+func (fuzzy *fuzzyMatcher) compare(word, lcword string, wordlen int) {
+	distance := fuzzy.metric.Distance(fuzzy.lcpass, lcword)
+
+	// a word which has to be rewritten completely is no match
+	if distance >= wordlen {
+		return
+	}
+
+	if !fuzzyAcceptable(distance, Max(fuzzy.passlen, wordlen), fuzzy.maxdist, fuzzy.minsim) {
+		return
+	}
+
+	best := fuzzy.best
+	if best.distance != -1 && distance > best.distance {
+		return
+	}
+
+	// only acceptable words get this far, so comparing them again is cheap
+	similarity := fuzzy.metric.Compare(fuzzy.lcpass, lcword)
+
+	if best.distance == -1 || distance < best.distance ||
+		(distance == best.distance && similarity > best.similarity) ||
+		(distance == best.distance && similarity == best.similarity && word < best.word) {
+		fuzzy.best = dictMatch{found: true, word: word, distance: distance, similarity: similarity}
+	}
+}
+
+// Returns true if  the given distance is within one  of the (enabled)
+// fuzzy thresholds.
+func fuzzyAcceptable(distance, maxlen, maxdist int, minsim float64) bool {
+	if maxdist > 0 && distance <= maxdist {
+		return true
+	}
+
+	if minsim > 0 && maxlen > 0 && similarity(distance, maxlen) >= minsim {
+		return true
+	}
+
+	return false
 }
@@ -3,9 +3,10 @@ package valpass_test
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
-	"os/exec"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/tlinden/valpass"
@@ -187,6 +188,29 @@ var pass_dictsub_bad = []string{
 	`terrevolut`, `icularizat`, `communicat`,
 }
 
+var pass_dictfuzzy_bad = []string{
+	`passw0rd1`, `Passwort`, `monkey1`, `dragonn`,
+	`sunshin3`, `secret12`, `horsey`, `batterry`,
+}
+
+var pass_unicode_good = []string{
+	`Bärenstärke§Öl€Zürich`,
+	`Übermäßig+Schön$Flüße7`,
+	`パスワード安全性の確認テスト`,
+	`東京タワー⇒富士山ξ漢字`,
+	`ÆøÅ-ßÇ¿¡ñÑ~µ`,
+}
+
+var pass_leet_bad = []string{
+	`p@ssw0rd`, `$unsh1ne`, `m0nk3y`, `dr4g0n`, `h0r$e`,
+	`s3cr3t`, `b4tt3ry`, `5ummer`, `P4SSW0RD`, `1ov3`,
+}
+
+var pass_sequence_bad = []string{
+	`abcdef`, `aaaa1111`, `abcabc`, `654321`, `xyzzyx`,
+	`blahblah`, `racecar`, `Summer1234`, `hello!!!!`, `ZYXW`,
+}
+
 var pass_invalid = []string{
 	string([]byte{12, 16, 45, 65, 96, 145}),
 }
@@ -212,6 +236,41 @@ var opts_dictsub = valpass.Options{
 	Dictionary:       &valpass.Dictionary{Words: ReadDict("t/american-english"), Submatch: true},
 }
 
+var opts_dictfuzzy = valpass.Options{
+	Compress:         0,
+	CharDistribution: 0,
+	Entropy:          0,
+	Dictionary:       &valpass.Dictionary{Words: ReadDict("t/american-english"), Fuzzy: true},
+}
+
+var dict_compiled = CompileDict("t/american-english", true, true)
+
+var opts_dictcompiled = valpass.Options{
+	Compress:         0,
+	CharDistribution: 0,
+	Entropy:          0,
+	Dictionary:       dict_compiled,
+}
+
+var opts_policy = valpass.Options{
+	MinLength:  12,
+	MaxLength:  64,
+	MinClasses: 2,
+}
+
+var opts_dictleet = valpass.Options{
+	Dictionary: &valpass.Dictionary{Words: dict_compiled.Words, Leet: true},
+}
+
+var opts_sequence = valpass.Options{
+	Sequence: valpass.MIN_SEQUENCE,
+}
+
+var opts_guesses = valpass.Options{
+	GuessesLog10: valpass.MIN_GUESSES_LOG10,
+	Dictionary:   dict_compiled,
+}
+
 var opts_invaliddict = valpass.Options{
 	Compress:         0,
 	CharDistribution: 0,
@@ -226,6 +285,12 @@ var tests = []Test{
 		opts:      opts_std,
 		passwords: Passwordlist{pass_random_good, pass_diceware_good},
 	},
+	{
+		name:      "checkgood-unicode",
+		want:      true,
+		opts:      opts_std,
+		passwords: Passwordlist{pass_unicode_good},
+	},
 	{
 		name:      "checkgood-dict",
 		want:      true,
@@ -250,6 +315,78 @@ var tests = []Test{
 		opts:      opts_dict,
 		passwords: Passwordlist{pass_dict_bad},
 	},
+	{
+		name:      "checkgood-dictfuzzy",
+		want:      true,
+		opts:      opts_dictfuzzy,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good},
+	},
+	{
+		name:      "checkbad-dictfuzzy",
+		want:      false,
+		opts:      opts_dictfuzzy,
+		passwords: Passwordlist{pass_dictfuzzy_bad, pass_dict_bad},
+	},
+	{
+		name:      "checkgood-dictcompiled",
+		want:      true,
+		opts:      opts_dictcompiled,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good},
+	},
+	{
+		name:      "checkbad-dictcompiled",
+		want:      false,
+		opts:      opts_dictcompiled,
+		passwords: Passwordlist{pass_dict_bad, pass_dictsub_bad, pass_dictfuzzy_bad},
+	},
+	{
+		name:      "checkgood-policy",
+		want:      true,
+		opts:      opts_policy,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good},
+	},
+	{
+		name:      "checkbad-policy",
+		want:      false,
+		opts:      opts_policy,
+		passwords: Passwordlist{pass_worst_bad, pass_dictsub_bad},
+	},
+	{
+		name:      "checkgood-dictleet",
+		want:      true,
+		opts:      opts_dictleet,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good},
+	},
+	{
+		name:      "checkbad-dictleet",
+		want:      false,
+		opts:      opts_dictleet,
+		passwords: Passwordlist{pass_leet_bad, pass_dict_bad},
+	},
+	{
+		name:      "checkgood-sequence",
+		want:      true,
+		opts:      opts_sequence,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good, pass_unicode_good},
+	},
+	{
+		name:      "checkbad-sequence",
+		want:      false,
+		opts:      opts_sequence,
+		passwords: Passwordlist{pass_sequence_bad},
+	},
+	{
+		name:      "checkgood-guesses",
+		want:      true,
+		opts:      opts_guesses,
+		passwords: Passwordlist{pass_random_good, pass_diceware_good},
+	},
+	{
+		name:      "checkbad-guesses",
+		want:      false,
+		opts:      opts_guesses,
+		passwords: Passwordlist{pass_worst_bad, pass_dict_bad, pass_sequence_bad},
+	},
 	{
 		name:      "checkinvalid",
 		want:      false,
@@ -278,6 +415,553 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	result, err := valpass.Validate("passw0rd1", opts_dictfuzzy)
+	if err != nil {
+		t.Fatalf("fuzzy validation failed with error: %s", err)
+	}
+
+	if result.Ok || !result.DictionaryMatch {
+		t.Errorf("fuzzy validation did not flag passw0rd1: %v", result)
+	}
+
+	if result.DictionaryWord != "password" || result.DictionaryDistance != 2 {
+		t.Errorf("expected closest word password with distance 2, got %s with %d",
+			result.DictionaryWord, result.DictionaryDistance)
+	}
+
+	if result.DictionarySimilarity < 0.77 || result.DictionarySimilarity > 0.78 {
+		t.Errorf("unexpected similarity %0.2f", result.DictionarySimilarity)
+	}
+}
+
+func TestCompiledDictionary(t *testing.T) {
+	t.Parallel()
+
+	small := valpass.Dictionary{Words: []string{"eins", "zwei", "drei"}}
+	if err := small.Compile(); err == nil {
+		t.Errorf("compiling a too small dictionary did not fail")
+	}
+
+	// compare against the uncompiled dictionary
+	plain := valpass.Options{
+		Dictionary: &valpass.Dictionary{Words: dict_compiled.Words, Submatch: true, Fuzzy: true},
+	}
+
+	for _, pass := range append(pass_dictsub_bad, pass_dictfuzzy_bad...) {
+		want, err := valpass.Validate(pass, plain)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		got, err := valpass.Validate(pass, opts_dictcompiled)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if want.DictionaryMatch != got.DictionaryMatch ||
+			want.DictionaryDistance != got.DictionaryDistance {
+			t.Errorf("compiled lookup of %s differs. want: %v, got: %v", pass, want, got)
+		}
+	}
+}
+
+func TestCompiledDictionaryConcurrent(t *testing.T) {
+	t.Parallel()
+
+	var wg sync.WaitGroup
+
+	for _, pass := range pass_dict_bad {
+		wg.Add(1)
+
+		go func(pass string) {
+			defer wg.Done()
+
+			result, err := valpass.Validate(pass, opts_dictcompiled)
+			if err != nil || result.Ok {
+				t.Errorf("concurrent lookup of %s failed: %v %v", pass, err, result)
+			}
+		}(pass)
+	}
+
+	wg.Wait()
+}
+
+func TestDistributionNoPanic(t *testing.T) {
+	t.Parallel()
+
+	// entropy is disabled, so nothing rejects these before the
+	// distribution is being measured
+	opts := valpass.Options{CharDistribution: valpass.MIN_DIST}
+
+	for _, pass := range append(pass_invalid, "\x00\x01\x7f\xff", "äöü", "") {
+		if _, err := valpass.Validate(pass, opts); err != nil {
+			t.Errorf("distribution check of %q failed with error: %s", pass, err)
+		}
+	}
+
+	result, err := valpass.Validate(`パスワード`, valpass.Options{CharDistribution: 1, AlphabetSize: 5})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.CharDistribution != 100 {
+		t.Errorf("expected a distribution of 100%% using a 5 char alphabet, got %0.2f", result.CharDistribution)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	opts := valpass.Options{
+		MinLength:     8,
+		MaxLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	var policytests = []struct {
+		pass   string
+		failed []string
+	}{
+		{`Ab1!efgh`, []string{}},
+		{`Ab1!`, []string{valpass.CHECK_MINLENGTH}},
+		{`Ab1!efghijk`, []string{valpass.CHECK_MAXLENGTH}},
+		{`ab1!efgh`, []string{valpass.CHECK_UPPER}},
+		{`AB1!EFGH`, []string{valpass.CHECK_LOWER}},
+		{`Abc!efgh`, []string{valpass.CHECK_DIGIT}},
+		{`Ab12efgh`, []string{valpass.CHECK_SYMBOL}},
+		{`äöü`, []string{valpass.CHECK_MINLENGTH, valpass.CHECK_UPPER,
+			valpass.CHECK_DIGIT, valpass.CHECK_SYMBOL}},
+	}
+
+	for _, tt := range policytests {
+		result, err := valpass.Validate(tt.pass, opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		failed := []string{}
+		for _, failure := range result.Failures {
+			failed = append(failed, failure.Check)
+		}
+
+		if strings.Join(failed, ",") != strings.Join(tt.failed, ",") {
+			t.Errorf("policy check of %s failed. want: %v, got: %v", tt.pass, tt.failed, failed)
+		}
+	}
+
+	result, err := valpass.Validate(`Ab1!`, valpass.Options{MinClasses: 3})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !result.Ok || result.Classes != 4 || result.Length != 4 {
+		t.Errorf("unexpected class result: %v", result)
+	}
+
+	result, err = valpass.Validate(`abcdefgh`, valpass.Options{MinClasses: 2})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Ok || result.Failures[0].Check != valpass.CHECK_CLASSES {
+		t.Errorf("password with one class has not been flagged: %v", result)
+	}
+}
+
+func TestLeet(t *testing.T) {
+	t.Parallel()
+
+	result, err := valpass.Validate(`Tr0ub4d0ur`, opts_dictleet)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	want := map[string]string{"0": "o", "4": "a"}
+
+	if result.Ok || result.DictionaryWord != "troubadour" || fmt.Sprint(result.DictionaryLeet) != fmt.Sprint(want) {
+		t.Errorf("l33t password not detected properly. want: %v, got: %v", want, result)
+	}
+
+	// 1 => i and 1 => l must not be mixed
+	result, err = valpass.Validate(`1111`, valpass.Options{
+		Dictionary: &valpass.Dictionary{Words: append(dict_compiled.Words, "ilil"), Leet: true},
+	})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !result.Ok {
+		t.Errorf("inconsistent l33t substitution matched: %v", result)
+	}
+
+	custom := valpass.Options{
+		Dictionary: &valpass.Dictionary{
+			Words:     dict_compiled.Words,
+			Leet:      true,
+			LeetTable: map[rune][]rune{'#': {'h'}},
+		},
+	}
+
+	for pass, want := range map[string]bool{`#orse`: false, `h0rse`: true} {
+		result, err = valpass.Validate(pass, custom)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Ok != want {
+			t.Errorf("custom l33t table lookup of %s failed. want: %t, got: %v", pass, want, result)
+		}
+	}
+}
+
+// many l33t candidates each, which must not all be scanned fuzzy
+var pass_dictleetfuzzy = []string{`p4$$w0rd!`, `Xk9#mQ2$vL7!`}
+
+var opts_dictleetfuzzy = valpass.Options{
+	Dictionary: CompileLeetDict(dict_compiled.Words),
+}
+
+func TestLeetFuzzy(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range []string{`p4ssw0rd`, `p4ssw0rd1`, `P@ssw0rd!`, `passw0rd1`} {
+		result, err := valpass.Validate(pass, opts_dictleetfuzzy)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Ok || result.DictionaryWord != "password" {
+			t.Errorf("l33t password %s not detected with fuzzy matching: %v", pass, result)
+		}
+	}
+}
+
+// Only a few de-l33ted candidates are scanned fuzzy, so l33t costs
+// little on top of fuzzy matching. Allocations are counted instead of
+// timing it, therefore the test does not run in parallel.
+func TestLeetFuzzyWork(t *testing.T) {
+	fuzzy := valpass.Options{Dictionary: CompileDict("t/american-english", false, true)}
+
+	validate := func(opts valpass.Options) func() {
+		return func() {
+			for _, pass := range pass_dictleetfuzzy {
+				if _, err := valpass.Validate(pass, opts); err != nil {
+					t.Fatalf("validation failed with error: %s", err)
+				}
+			}
+		}
+	}
+
+	want := testing.AllocsPerRun(1, validate(fuzzy)) * 8
+
+	if got := testing.AllocsPerRun(1, validate(opts_dictleetfuzzy)); got > want {
+		t.Errorf("l33t and fuzzy lookups need too much work: %.0f allocations, want at most %.0f", got, want)
+	}
+}
+
+func TestGuesses(t *testing.T) {
+	t.Parallel()
+
+	good, err := valpass.Validate(`correcthorsebatterystaple`, opts_guesses)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	bad, err := valpass.Validate(`Password1!Password1!`, opts_guesses)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if !good.Ok || bad.Ok || good.Guesses <= bad.Guesses {
+		t.Errorf("guess estimation is off. good: %0.2f, bad: %0.2f", good.GuessesLog10, bad.GuessesLog10)
+	}
+
+	times := good.CrackTimes
+	if !(times.OnlineThrottled > times.OnlineUnthrottled &&
+		times.OnlineUnthrottled > times.OfflineSlowHash &&
+		times.OfflineSlowHash > times.OfflineFastHash &&
+		times.OfflineFastHash > 0) {
+		t.Errorf("crack times are not ordered by attack speed: %v", times)
+	}
+
+	if bad.Failures[0].Check != valpass.CHECK_GUESSES {
+		t.Errorf("unexpected failure: %v", bad.Failures)
+	}
+}
+
+// long passwords repeating a short block are as weak as the block
+var pass_guesses_long = []string{
+	strings.Repeat(`a`, 120),
+	strings.Repeat(`abcdefghij`, 12),
+	strings.Repeat(`ab`, 51),
+	strings.Repeat(`abccba`+`horsesesroh`, 40),
+}
+
+var opts_guesses_long = valpass.Options{
+	GuessesLog10: valpass.MIN_GUESSES_LOG10,
+	Dictionary:   dict_compiled,
+	UserInputs:   []string{"john.doe@example.com"},
+}
+
+func TestGuessesLongPassword(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range pass_guesses_long {
+		result, err := valpass.Validate(pass, opts_guesses_long)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Ok || result.GuessesLog10 >= valpass.MIN_GUESSES_LOG10 || result.Score > 1 {
+			t.Errorf("long repetitive password got too many guesses: %0.2f, score %d",
+				result.GuessesLog10, result.Score)
+		}
+	}
+
+	// a repeated block counts as the block times its repetitions
+	block, err := valpass.Validate(pass_random_good[0], opts_guesses_long)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	repeated, err := valpass.Validate(strings.Repeat(pass_random_good[0], 125), opts_guesses_long)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if want := block.GuessesLog10 + math.Log10(125); math.Abs(repeated.GuessesLog10-want) > 0.01 {
+		t.Errorf("repeated block got unexpected guesses. want: %0.2f, got: %0.2f",
+			want, repeated.GuessesLog10)
+	}
+}
+
+// Long passwords are scored in parts, so the work grows at most
+// linearly with the length. Allocations are counted instead of timing
+// it, therefore the test does not run in parallel.
+func TestGuessesLongPasswordWork(t *testing.T) {
+	long := strings.Join(pass_random_good, "")
+	short := long[:100]
+
+	validate := func(pass string) func() {
+		return func() {
+			if _, err := valpass.Validate(pass, opts_guesses_long); err != nil {
+				t.Fatalf("validation failed with error: %s", err)
+			}
+		}
+	}
+
+	parts := float64(len(long)/100 + 1)
+	want := testing.AllocsPerRun(1, validate(short)) * parts * 2
+
+	if got := testing.AllocsPerRun(1, validate(long)); got > want {
+		t.Errorf("guess estimation of %d chars needs too much work: %.0f allocations, want at most %.0f",
+			len(long), got, want)
+	}
+}
+
+var opts_passphrase_score = valpass.Options{Dictionary: dict_compiled, Passphrase: true}
+
+func TestScore(t *testing.T) {
+	t.Parallel()
+
+	var scoretests = []struct {
+		pass  string
+		opts  valpass.Options
+		score int
+	}{
+		{`aaaaaaaaaaaaaaaaaaaa`, opts_std, 0},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, opts_std, 4},
+		{`clock`, opts_dict, 1},
+		{``, opts_std, 0},
+		// exactly MIN_ENTROPY bits/char fails the validation, so it
+		// must not be fair
+		{`abcdefgh`, valpass.Options{Entropy: valpass.MIN_ENTROPY}, 1},
+		{`abcdefgh`, valpass.Options{TotalEntropy: 1}, 0},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, valpass.Options{PoolEntropy: 1}, 4},
+		{`password`, opts_guesses, 0},
+		// failed pattern checks cap the score
+		{`Xk9#vQ2!mZ7qwertyuiop`, valpass.Options{
+			TotalEntropy: 1, KeyboardWalk: valpass.MIN_KEYBOARD_WALK}, 1},
+		{`Xk9#vQ2!mZ7@johndoe`, valpass.Options{
+			TotalEntropy: 1, UserInputs: []string{"john.doe@example.com"}}, 1},
+		{`Xk9#vQ2!mZ7aaaaaa`, valpass.Options{
+			TotalEntropy: 1, Sequence: valpass.MIN_SEQUENCE}, 1},
+		{`Xk9#vQ2!mZ7@1987`, valpass.Options{TotalEntropy: 1, Dates: true}, 1},
+		// so do policy violations
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, valpass.Options{
+			TotalEntropy: 1, RequireSymbol: true}, 1},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, valpass.Options{
+			TotalEntropy: 1, MaxLength: 32}, 1},
+		{`correct horse battery staple horse`, opts_passphrase_score, 1},
+		{`correct horse battery staple on`, opts_passphrase_score, 1},
+		{`correct horse battery staple`, opts_passphrase_score, 4},
+		// breaches below the minimum count pass, so they don't cap it
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, valpass.Options{
+			TotalEntropy: 1, Breach: fixedBreach(3), BreachMinCount: 10}, 4},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, valpass.Options{
+			TotalEntropy: 1, Breach: fixedBreach(10), BreachMinCount: 10}, 0},
+	}
+
+	for _, tt := range scoretests {
+		result, err := valpass.Validate(tt.pass, tt.opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Score != tt.score || result.ScoreLabel != valpass.SCORE_LABELS[tt.score] {
+			t.Errorf("unexpected score for %q. want: %d, got: %d (%s)",
+				tt.pass, tt.score, result.Score, result.ScoreLabel)
+		}
+	}
+
+	// custom cutoffs
+	cutoffs := valpass.DEFAULT_SCORE_CUTOFFS
+	cutoffs.Entropy = [4]float64{6, 7, 8, 9}
+
+	result, err := valpass.Validate(`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`,
+		valpass.Options{Entropy: 1, ScoreCutoffs: &cutoffs})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Score != 0 {
+		t.Errorf("custom cutoffs not respected, got score %d", result.Score)
+	}
+
+	// nothing measured, no score
+	result, err = valpass.Validate(`clock`, valpass.Options{})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.ScoreLabel != "" {
+		t.Errorf("got a score without any metrics: %s", result.ScoreLabel)
+	}
+}
+
+func TestEntropyBits(t *testing.T) {
+	t.Parallel()
+
+	var bitstests = []struct {
+		pass  string
+		total float64
+		pool  float64
+	}{
+		{`aaaaaaaa`, 0, 8 * math.Log2(26)},
+		{`abcdabcd`, 16, 8 * math.Log2(26)},
+		{`ab12`, 8, 4 * math.Log2(36)},
+		{`aB1!`, 8, 4 * math.Log2(95)},
+		{``, 0, 0},
+	}
+
+	opts := valpass.Options{TotalEntropy: 60, PoolEntropy: 60}
+
+	for _, tt := range bitstests {
+		result, err := valpass.Validate(tt.pass, opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if math.Abs(result.TotalEntropy-tt.total) > 1e-9 || math.Abs(result.PoolEntropy-tt.pool) > 1e-9 {
+			t.Errorf("unexpected entropy for %q. want: %.2f/%.2f bits, got: %.2f/%.2f bits",
+				tt.pass, tt.total, tt.pool, result.TotalEntropy, result.PoolEntropy)
+		}
+
+		if result.Ok || len(result.Failures) != 2 {
+			t.Errorf("%q: expected total and pool entropy to fail: %v", tt.pass, result.Failures)
+		}
+	}
+
+	// a length of 40 reaches 60 bits easily, length 4 doesn't
+	CheckPassword(t, `oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, Test{name: "checkgood-bits", want: true, opts: opts})
+	CheckPassword(t, `x7Q!`, Test{name: "checkbad-bits", want: false, opts: opts})
+}
+
+func TestSequences(t *testing.T) {
+	t.Parallel()
+
+	var sequencetests = []struct {
+		pass      string
+		sequences []valpass.Sequence
+	}{
+		{`x12345y`, []valpass.Sequence{{Kind: valpass.SEQUENCE_ASCENDING, Token: "12345", Start: 1, Length: 5}}},
+		{`FeDcB`, []valpass.Sequence{{Kind: valpass.SEQUENCE_DESCENDING, Token: "FeDcB", Start: 0, Length: 5}}},
+		{`aaaa1111`, []valpass.Sequence{
+			{Kind: valpass.SEQUENCE_REPEAT, Token: "aaaa", Start: 0, Length: 4},
+			{Kind: valpass.SEQUENCE_REPEAT, Token: "1111", Start: 4, Length: 4},
+		}},
+		{`!x9x9x9`, []valpass.Sequence{{Kind: valpass.SEQUENCE_BLOCK, Token: "x9x9x9", Start: 1, Length: 6}}},
+		{`q#xkyykx`, []valpass.Sequence{{Kind: valpass.SEQUENCE_MIRROR, Token: "xkyykx", Start: 2, Length: 6}}},
+		{`k3#Pq!`, []valpass.Sequence{}},
+		{`aabaab`, []valpass.Sequence{{Kind: valpass.SEQUENCE_BLOCK, Token: "aabaab", Start: 0, Length: 6}}},
+		{strings.Repeat(`a`, 2000), []valpass.Sequence{
+			{Kind: valpass.SEQUENCE_REPEAT, Token: strings.Repeat(`a`, 2000), Start: 0, Length: 2000},
+		}},
+	}
+
+	for _, tt := range sequencetests {
+		result, err := valpass.Validate(tt.pass, valpass.Options{Sequence: 100})
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if len(result.Sequences) != len(tt.sequences) {
+			t.Fatalf("unexpected sequences in %s. want: %v, got: %v", tt.pass, tt.sequences, result.Sequences)
+		}
+
+		for i, sequence := range result.Sequences {
+			if sequence != tt.sequences[i] {
+				t.Errorf("unexpected sequence in %s. want: %v, got: %v", tt.pass, tt.sequences[i], sequence)
+			}
+		}
+	}
+}
+
+func TestFailures(t *testing.T) {
+	t.Parallel()
+
+	result, err := valpass.Validate(`aaaaaaaaaaaaaaaaaaaaa`)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	checks := map[string]bool{}
+	for _, failure := range result.Failures {
+		checks[failure.Check] = true
+
+		if failure.Message == "" {
+			t.Errorf("failure of check %s has no message", failure.Check)
+		}
+	}
+
+	for _, check := range []string{valpass.CHECK_ENTROPY, valpass.CHECK_COMPRESS, valpass.CHECK_CHARDIST} {
+		if !checks[check] {
+			t.Errorf("expected failure of check %s, got: %v", check, result.Failures)
+		}
+	}
+
+	result, err = valpass.Validate(`passw0rd1`, opts_dictfuzzy)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if len(result.Failures) != 1 {
+		t.Fatalf("expected one failure, got: %v", result.Failures)
+	}
+
+	failure := result.Failures[0]
+	if failure.Check != valpass.CHECK_DICTIONARY || failure.Value != 2 ||
+		failure.Threshold != float64(valpass.MAX_FUZZY_DISTANCE) {
+		t.Errorf("unexpected dictionary failure: %v", failure)
+	}
+}
+
 func CheckPassword(t *testing.T, password string, tt Test) {
 
 	result, err := valpass.Validate(password, tt.opts)
@@ -297,6 +981,11 @@ func CheckPassword(t *testing.T, password string, tt Test) {
 		t.Errorf("test %s failed. pass: %s, want: %t, got: %t, dict: %t\nresult: %v\n",
 			tt.name, password, tt.want, result.Ok, result.DictionaryMatch, result)
 	}
+
+	if result.Ok != (len(result.Failures) == 0) {
+		t.Errorf("test %s failed. pass: %s, ok: %t, but failures: %v\n",
+			tt.name, password, result.Ok, result.Failures)
+	}
 }
 
 func BenchmarkValidateEntropy(b *testing.B) {
@@ -345,6 +1034,69 @@ func BenchmarkValidateDict(b *testing.B) {
 	}
 }
 
+func BenchmarkValidateDictCompiled(b *testing.B) {
+	passwords := GetPasswords(b.N)
+	opts := valpass.Options{Dictionary: CompileDict("t/american-english", false, false)}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := valpass.Validate(passwords[i], opts)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// l33t candidates are looked up exactly, only the password itself and
+// the fully de-l33ted versions are matched fuzzy
+func BenchmarkValidateDictLeetFuzzy(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := valpass.Validate(pass_dictleetfuzzy[i%len(pass_dictleetfuzzy)], opts_dictleetfuzzy)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// blocks are not looked for within runs of one char
+func BenchmarkValidateSequenceLong(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := valpass.Validate(strings.Repeat(`a`, 2000), valpass.Options{Sequence: valpass.MIN_SEQUENCE})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkValidateGuessesLong(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := valpass.Validate(pass_guesses_long[i%len(pass_guesses_long)], opts_guesses_long)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// the passphrase check and the guess estimator share the hash set of
+// the uncompiled dictionary, it is built once per call
+func BenchmarkValidatePassphrase(b *testing.B) {
+	opts := valpass.Options{
+		Dictionary:   &valpass.Dictionary{Words: ReadDict("t/american-english")},
+		Passphrase:   true,
+		GuessesLog10: valpass.MIN_GUESSES_LOG10,
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := valpass.Validate(`correct-horse-battery-staple`, opts)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
 func BenchmarkValidateAll(b *testing.B) {
 	passwords := GetPasswords(b.N)
 
@@ -384,14 +1136,40 @@ func ReadDict(path string) []string {
 	return lines
 }
 
-func GetPasswords(count int) []string {
+func CompileDict(path string, submatch, fuzzy bool) *valpass.Dictionary {
+	dict := &valpass.Dictionary{Words: ReadDict(path), Submatch: submatch, Fuzzy: fuzzy}
+
+	if err := dict.Compile(); err != nil {
+		panic(err)
+	}
+
+	return dict
+}
 
-	cmd := exec.Command("pwgen", "-1", "-s", "-y", "32", fmt.Sprintf("%d", count+1))
+func CompileLeetDict(words []string) *valpass.Dictionary {
+	dict := &valpass.Dictionary{Words: words, Fuzzy: true, Leet: true}
 
-	out, err := cmd.Output()
+	if err := dict.Compile(); err != nil {
+		panic(err)
+	}
+
+	return dict
+}
+
+func GetPasswords(count int) []string {
+	generator, err := valpass.NewGenerator(valpass.GeneratorOptions{Length: 32}, valpass.Options{})
 	if err != nil {
-		panic(cmd.Err)
+		panic(err)
+	}
+
+	passwords := make([]string, count)
+
+	for i := range passwords {
+		passwords[i], err = generator.Generate()
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	return strings.Split(string(out), "\n")
+	return passwords
 }
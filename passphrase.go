@@ -0,0 +1,194 @@
+package valpass
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	PASSPHRASE_WORDS       int     = 4  // default minimum number of words
+	PASSPHRASE_WORD_LENGTH int     = 3  // default minimum length of every word
+	PASSPHRASE_ENTROPY     float64 = 50 // default minimum word-level entropy in bits
+
+	// shorter tokens are never counted as dictionary words, single
+	// letters are in most word lists and would turn random strings
+	// into passphrases
+	passphrase_min_token int = 2
+
+	// share of the letters which must belong to dictionary words
+	passphrase_min_coverage float64 = 0.75
+)
+
+// passphraseMatch describes a detected passphrase.
+type passphraseMatch struct {
+	found    bool
+	words    int     // number of words
+	shortest int     // length of the shortest word in runes
+	repeated bool    // true if a word occurs more than once
+	entropy  float64 // word-level entropy in bits
+}
+
+/*
+Split the password into words. Words are separated by anything which
+is not a letter, e.g. spaces, dashes or digits. If there is no such
+separator, camel case is being used, e.g. "CorrectHorseBatteryStaple".
+The words are lower cased.
+*/
+func getPassphraseWords(passphrase string) []string {
+	words := strings.FieldsFunc(passphrase, func(char rune) bool {
+		return !unicode.IsLetter(char)
+	})
+
+	if len(words) == 1 {
+		words = splitCamelCase(words[0])
+	}
+
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+
+	return words
+}
+
+// Split a word before every upper case letter which follows a lower
+// case one.
+func splitCamelCase(word string) []string {
+	words := []string{}
+	start := 0
+	previous := rune(0)
+
+	for pos, char := range word {
+		if unicode.IsUpper(char) && unicode.IsLower(previous) {
+			words = append(words, word[start:pos])
+			start = pos
+		}
+
+		previous = char
+	}
+
+	return append(words, word[start:])
+}
+
+/*
+Detect a passphrase made of dictionary words. It is a passphrase if it
+consists of at least two words and the dictionary words cover most of
+its letters.
+
+The entropy is calculated on word level: every distinct dictionary word
+adds log2(dictionary size) bits, because an attacker would pick words
+from a list, not chars. Other words add log2(26) bits per letter,
+separators and repetitions add nothing.
+*/
+func getPassphrase(passphrase string, words *wordSet) passphraseMatch {
+	tokens := getPassphraseWords(passphrase)
+	if len(tokens) < 2 {
+		return passphraseMatch{}
+	}
+
+	wordbits := math.Log2(float64(Max(words.size, 1)))
+	charbits := math.Log2(float64(len(ALPHABET_LOWER)))
+
+	match := passphraseMatch{words: len(tokens), shortest: math.MaxInt}
+	seen := map[string]bool{}
+	letters, known := 0, 0
+
+	for _, word := range tokens {
+		length := utf8.RuneCountInString(word)
+		letters += length
+		match.shortest = Min(match.shortest, length)
+
+		isword := length >= passphrase_min_token && words.contains(word)
+		if isword {
+			known += length
+		}
+
+		if seen[word] {
+			match.repeated = true
+			continue
+		}
+
+		seen[word] = true
+
+		if isword {
+			match.entropy += wordbits
+		} else {
+			match.entropy += float64(length) * charbits
+		}
+	}
+
+	match.found = float64(known) >= float64(letters)*passphrase_min_coverage
+
+	return match
+}
+
+/*
+Apply the passphrase thresholds if the password is a passphrase. The
+per char checks skip the password in that case, they don't make sense
+for words.
+*/
+func checkPassphrase(passphrase string, check PassphraseCheck, result *Result) error {
+	if check.Dictionary == nil {
+		return fmt.Errorf("passphrase check requires a dictionary")
+	}
+
+	if err := checkPrintable(passphrase); err != nil {
+		return err
+	}
+
+	words := check.words
+	if words == nil {
+		words = check.Dictionary.wordSet
+	}
+
+	match := getPassphrase(passphrase, words())
+	if !match.found {
+		return nil
+	}
+
+	minwords := check.Words
+	if minwords == 0 {
+		minwords = PASSPHRASE_WORDS
+	}
+
+	minlength := check.WordLength
+	if minlength == 0 {
+		minlength = PASSPHRASE_WORD_LENGTH
+	}
+
+	minentropy := check.Entropy
+	if minentropy == 0 {
+		minentropy = PASSPHRASE_ENTROPY
+	}
+
+	result.Passphrase = true
+	result.PassphraseWords = match.words
+	result.PassphraseEntropy = match.entropy
+	result.SetMetric(CHECK_PASSPHRASE, match.entropy)
+
+	// the words are part of the password, never put them into messages
+	if match.words < minwords {
+		result.Fail(CHECK_WORDS, float64(minwords), float64(match.words),
+			fmt.Sprintf("passphrase consists of %d words, it must contain at least %d", match.words, minwords))
+	}
+
+	if match.shortest < minlength {
+		result.Fail(CHECK_WORDLENGTH, float64(minlength), float64(match.shortest),
+			fmt.Sprintf("passphrase contains a word of %d characters, every word must contain at least %d",
+				match.shortest, minlength))
+	}
+
+	if match.repeated {
+		result.Fail(CHECK_REPEATED, 0, 1, "passphrase contains a word more than once")
+	}
+
+	if match.entropy < minentropy {
+		result.Fail(CHECK_PASSPHRASE, minentropy, match.entropy,
+			fmt.Sprintf("passphrase entropy of %.2f bits is too low, it must be at least %.2f bits",
+				match.entropy, minentropy))
+	}
+
+	return nil
+}
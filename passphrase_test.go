@@ -0,0 +1,118 @@
+package valpass_test
+
+import (
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var pass_passphrase_bad = []string{
+	`correct horse battery`,           // too few words
+	`correct-horse-battery-staple-of`, // word too short
+	`staple horse battery staple`,     // repeated word
+	`HorseHorseHorseHorse`,            // repeated words, camel case
+	`battery staple horse a`,          // word too short
+}
+
+var opts_passphrase = valpass.Options{
+	Compress:         valpass.MIN_COMPRESS,
+	CharDistribution: valpass.MIN_DIST,
+	Entropy:          valpass.MIN_ENTROPY,
+	Dictionary:       opts_dict.Dictionary,
+	Passphrase:       true,
+}
+
+func TestPassphrase(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range pass_passphrase_bad {
+		CheckPassword(t, pass, Test{name: "checkbad-passphrase", want: false, opts: opts_passphrase})
+	}
+
+	for _, pass := range append(pass_random_good, pass_diceware_good...) {
+		CheckPassword(t, pass, Test{name: "checkgood-passphrase", want: true, opts: opts_passphrase})
+	}
+}
+
+func TestPassphraseResult(t *testing.T) {
+	t.Parallel()
+
+	var phrasetests = []struct {
+		pass     string
+		detected bool
+		words    int
+		failures []string
+	}{
+		{`correct horse battery staple`, true, 4, nil},
+		{`CorrectHorseBatteryStaple`, true, 4, nil},
+		{`correct7horse7battery7staple`, true, 4, nil},
+		{`correct horse`, true, 2, []string{valpass.CHECK_WORDS, valpass.CHECK_PASSPHRASE}},
+		{`horse horse horse horse`, true, 4, []string{valpass.CHECK_REPEATED, valpass.CHECK_PASSPHRASE}},
+		{`go horse battery staple`, true, 4, []string{valpass.CHECK_WORDLENGTH}},
+		// random strings and l33t words are no passphrases
+		{`Tr0ub4dor&3`, false, 0, nil},
+		{`a b c d e f`, false, 0, []string{valpass.CHECK_ENTROPY, valpass.CHECK_CHARDIST}},
+		{`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, false, 0, nil},
+	}
+
+	opts := opts_passphrase
+	opts.Compress = 0
+
+	validator, err := valpass.NewValidator(opts)
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	for _, tt := range phrasetests {
+		result, err := validator.Validate(tt.pass)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if result.Passphrase != tt.detected || result.PassphraseWords != tt.words {
+			t.Errorf("%s: want passphrase %t with %d words, got: %t with %d words",
+				tt.pass, tt.detected, tt.words, result.Passphrase, result.PassphraseWords)
+		}
+
+		failures := []string{}
+		for _, failure := range result.Failures {
+			failures = append(failures, failure.Check)
+		}
+
+		if len(failures) != len(tt.failures) {
+			t.Errorf("%s: unexpected failures. want: %v, got: %v", tt.pass, tt.failures, failures)
+			continue
+		}
+
+		for i, check := range tt.failures {
+			if failures[i] != check {
+				t.Errorf("%s: unexpected failures. want: %v, got: %v", tt.pass, tt.failures, failures)
+			}
+		}
+
+		if _, ok := result.Metrics[valpass.CHECK_ENTROPY]; ok == tt.detected {
+			t.Errorf("%s: per char entropy measured for passphrase: %t", tt.pass, tt.detected)
+		}
+	}
+
+	// every distinct word adds log2(dictionary size) bits
+	first, _ := validator.Validate(`correct horse`)
+	second, _ := validator.Validate(`correct horse battery staple`)
+
+	if diff := second.PassphraseEntropy - 2*first.PassphraseEntropy; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("unexpected word entropy: %f vs %f", first.PassphraseEntropy, second.PassphraseEntropy)
+	}
+}
+
+func TestPassphraseOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := opts_passphrase
+	opts.PassphraseWords = 2
+	opts.PassphraseWordLength = 2
+	opts.PassphraseEntropy = 30
+
+	for _, pass := range []string{`correct horse`, `go horse battery staple`} {
+		CheckPassword(t, pass, Test{name: "checkgood-passphrase-tuned", want: true, opts: opts})
+	}
+}
@@ -0,0 +1,128 @@
+package valpass
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// charClasses records which character classes a password uses.
+type charClasses struct {
+	upper, lower, digit, symbol bool
+}
+
+/*
+Return the  character classes used  by the password.  Letters without
+case, e.g. kana, count as lower case, everything which is neither a
+letter nor a digit counts as symbol.
+*/
+func getClasses(passphrase string) charClasses {
+	var classes charClasses
+
+	for _, char := range passphrase {
+		switch classIndex(char) {
+		case class_upper:
+			classes.upper = true
+		case class_lower:
+			classes.lower = true
+		case class_digit:
+			classes.digit = true
+		default:
+			classes.symbol = true
+		}
+	}
+
+	return classes
+}
+
+const (
+	class_upper int = iota
+	class_lower
+	class_digit
+	class_symbol
+)
+
+// names of the classes, indexed by classIndex()
+var classNames = []string{"upper case letter", "lower case letter", "digit", "symbol"}
+
+// number of printable US ASCII chars per class, indexed by classIndex(),
+// symbols include the space
+var classPoolSizes = []int{
+	len(ALPHABET_UPPER),
+	len(ALPHABET_LOWER),
+	len(ALPHABET_DIGITS),
+	MAX_CHARS - len(ALPHABET_UPPER) - len(ALPHABET_LOWER) - len(ALPHABET_DIGITS),
+}
+
+// Returns the character class of a single char.
+func classIndex(char rune) int {
+	switch {
+	case unicode.IsUpper(char):
+		return class_upper
+	case unicode.IsLetter(char):
+		return class_lower
+	case unicode.IsDigit(char):
+		return class_digit
+	default:
+		return class_symbol
+	}
+}
+
+// Returns the number of character classes used.
+func (classes charClasses) count() int {
+	count := 0
+
+	for _, used := range []bool{classes.upper, classes.lower, classes.digit, classes.symbol} {
+		if used {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+Apply the length and character class rules. Each rule which has been
+violated is recorded as a separate failure.
+*/
+func checkPolicy(passphrase string, check PolicyCheck, result *Result) {
+	length := utf8.RuneCountInString(passphrase)
+	classes := getClasses(passphrase)
+
+	result.Length = length
+	result.Classes = classes.count()
+
+	if check.MinLength > 0 && length < check.MinLength {
+		result.Fail(CHECK_MINLENGTH, float64(check.MinLength), float64(length),
+			fmt.Sprintf("password is too short, it must contain at least %d characters", check.MinLength))
+	}
+
+	if check.MaxLength > 0 && length > check.MaxLength {
+		result.Fail(CHECK_MAXLENGTH, float64(check.MaxLength), float64(length),
+			fmt.Sprintf("password is too long, it must not contain more than %d characters", check.MaxLength))
+	}
+
+	rules := []struct {
+		required bool
+		used     bool
+		check    string
+		name     string
+	}{
+		{check.RequireUpper, classes.upper, CHECK_UPPER, "an upper case letter"},
+		{check.RequireLower, classes.lower, CHECK_LOWER, "a lower case letter"},
+		{check.RequireDigit, classes.digit, CHECK_DIGIT, "a digit"},
+		{check.RequireSymbol, classes.symbol, CHECK_SYMBOL, "a special character"},
+	}
+
+	for _, rule := range rules {
+		if rule.required && !rule.used {
+			result.Fail(rule.check, 1, 0, fmt.Sprintf("password must contain %s", rule.name))
+		}
+	}
+
+	if check.MinClasses > 0 && result.Classes < check.MinClasses {
+		result.Fail(CHECK_CLASSES, float64(check.MinClasses), float64(result.Classes),
+			fmt.Sprintf("password must contain characters of at least %d of these kinds: "+
+				"upper case letters, lower case letters, digits and special characters", check.MinClasses))
+	}
+}
@@ -0,0 +1,247 @@
+package valpass
+
+/*
+ScoreCutoffs configures how the measured metrics are mapped into a
+score between 0 (very weak) and 4 (very strong), e.g. for a strength
+meter.
+
+Each metric reaches the highest score whose cutoff it satisfies, the
+overall score is the lowest score of all measured metrics. Metrics which
+have not been measured, because they are turned off, are not considered.
+Dictionary and breach hits, policy violations as well as failed
+pattern checks, like keyboard walks or reused passwords, cap the score.
+So do failures of custom checks, see Options.Checks.
+
+The cutoffs are compared the same way as the validation thresholds, so
+a metric which meets the threshold used for score 2 passes the
+validation with the same value.
+*/
+type ScoreCutoffs struct {
+	// entropy in bits/char must be above these for the scores 1, 2, 3 and 4
+	Entropy [4]float64
+
+	// minimum entropy of the whole password in bits for the scores 1-4
+	TotalEntropy [4]float64
+
+	// minimum pool entropy in bits for the scores 1-4
+	PoolEntropy [4]float64
+
+	// compression rate in percent must be below these for the scores 1-4
+	Compress [4]int
+
+	// character distribution in percent must be above these for the scores 1-4
+	CharDistribution [4]float64
+
+	// minimum number of guesses as log10 for the scores 1-4
+	GuessesLog10 [4]float64
+
+	// minimum word-level entropy of passphrases in bits for the scores 1-4
+	Passphrase [4]float64
+
+	// maximum score of passwords matching the dictionary
+	Dictionary int
+
+	// maximum score of passwords found in breach databases
+	Breach int
+
+	// maximum score of passwords violating the length, character
+	// class or passphrase word rules
+	Policy int
+
+	// maximum scores of passwords failing the pattern checks
+	Keyboard  int
+	Sequence  int
+	Date      int
+	UserInput int
+	Explained int
+
+	// maximum score of passwords found in the history
+	History int
+
+	// maximum score of passwords failing any other check, e.g. a
+	// custom one
+	Custom int
+}
+
+// DEFAULT_SCORE_CUTOFFS are used if Options.ScoreCutoffs is nil.
+var DEFAULT_SCORE_CUTOFFS = ScoreCutoffs{
+	Entropy:          [4]float64{2, MIN_ENTROPY, 3.5, 4},
+	TotalEntropy:     [4]float64{28, 36, 60, 80},
+	PoolEntropy:      [4]float64{28, 36, 60, 80},
+	Compress:         [4]int{50, 30, MIN_COMPRESS, 1},
+	CharDistribution: [4]float64{5, MIN_DIST, 15, 20},
+	GuessesLog10:     [4]float64{6, MIN_GUESSES_LOG10, 12, 14},
+	Passphrase:       [4]float64{20, 35, PASSPHRASE_ENTROPY, 65},
+	Dictionary:       1,
+	Breach:           0,
+	Policy:           1,
+	Keyboard:         1,
+	Sequence:         1,
+	Date:             1,
+	UserInput:        1,
+	Explained:        1,
+	History:          0,
+	Custom:           1,
+}
+
+// SCORE_LABELS contains the labels of the scores 0-4.
+var SCORE_LABELS = [5]string{"very weak", "weak", "fair", "strong", "very strong"}
+
+const MAX_SCORE int = 4
+
+// checks which are scored by their metric or result fields
+var score_metrics = map[string]bool{
+	CHECK_ENTROPY:    true,
+	CHECK_TOTAL:      true,
+	CHECK_POOL:       true,
+	CHECK_COMPRESS:   true,
+	CHECK_CHARDIST:   true,
+	CHECK_GUESSES:    true,
+	CHECK_PASSPHRASE: true,
+	CHECK_DICTIONARY: true,
+	CHECK_BREACH:     true,
+}
+
+/*
+Calculate the score from the metrics measured so far. If no metric has
+been measured at all, there is no score and the label stays empty.
+*/
+func getScore(result *Result, options Options) {
+	cutoffs := DEFAULT_SCORE_CUTOFFS
+	if options.ScoreCutoffs != nil {
+		cutoffs = *options.ScoreCutoffs
+	}
+
+	score := MAX_SCORE
+	measured := false
+
+	if _, ok := result.Metrics[CHECK_ENTROPY]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool { return result.Entropy > cutoffs.Entropy[i] }))
+	}
+
+	if _, ok := result.Metrics[CHECK_TOTAL]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool { return result.TotalEntropy >= cutoffs.TotalEntropy[i] }))
+	}
+
+	if _, ok := result.Metrics[CHECK_POOL]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool { return result.PoolEntropy >= cutoffs.PoolEntropy[i] }))
+	}
+
+	if _, ok := result.Metrics[CHECK_COMPRESS]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool { return result.Compress < cutoffs.Compress[i] }))
+	}
+
+	if _, ok := result.Metrics[CHECK_CHARDIST]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool {
+			return result.CharDistribution > cutoffs.CharDistribution[i]
+		}))
+	}
+
+	if _, ok := result.Metrics[CHECK_GUESSES]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool {
+			return result.GuessesLog10 >= cutoffs.GuessesLog10[i]
+		}))
+	}
+
+	if _, ok := result.Metrics[CHECK_PASSPHRASE]; ok {
+		measured = true
+		score = Min(score, scoreLevel(func(i int) bool {
+			return result.PassphraseEntropy >= cutoffs.Passphrase[i]
+		}))
+	}
+
+	if _, ok := result.Metrics[CHECK_DICTIONARY]; ok {
+		measured = true
+		if result.DictionaryMatch {
+			score = Min(score, cutoffs.Dictionary)
+		}
+	}
+
+	// a breach count below the minimum passes the validation, so it
+	// must not cap the score either
+	if result.failed(CHECK_BREACH) {
+		measured = true
+		score = Min(score, cutoffs.Breach)
+	}
+
+	// policy rules and pattern checks have no score of their own, but
+	// cap it on failure
+	caps := map[string]int{
+		CHECK_MINLENGTH:  cutoffs.Policy,
+		CHECK_MAXLENGTH:  cutoffs.Policy,
+		CHECK_UPPER:      cutoffs.Policy,
+		CHECK_LOWER:      cutoffs.Policy,
+		CHECK_DIGIT:      cutoffs.Policy,
+		CHECK_SYMBOL:     cutoffs.Policy,
+		CHECK_CLASSES:    cutoffs.Policy,
+		CHECK_WORDS:      cutoffs.Policy,
+		CHECK_WORDLENGTH: cutoffs.Policy,
+		CHECK_REPEATED:   cutoffs.Policy,
+		CHECK_KEYBOARD:   cutoffs.Keyboard,
+		CHECK_SEQUENCE:   cutoffs.Sequence,
+		CHECK_DATE:       cutoffs.Date,
+		CHECK_USERINPUT:  cutoffs.UserInput,
+		CHECK_EXPLAINED:  cutoffs.Explained,
+		CHECK_HISTORY:    cutoffs.History,
+	}
+
+	for check, limit := range caps {
+		if result.failed(check) {
+			measured = true
+			score = Min(score, limit)
+		}
+	}
+
+	// so do the checks unknown to us
+	for _, failure := range result.Failures {
+		if _, ok := caps[failure.Check]; !ok && !score_metrics[failure.Check] {
+			measured = true
+			score = Min(score, cutoffs.Custom)
+		}
+	}
+
+	if !measured {
+		return
+	}
+
+	if result.Length == 0 {
+		score = 0
+	}
+
+	score = Max(Min(score, MAX_SCORE), 0)
+
+	result.Score = score
+	result.ScoreLabel = SCORE_LABELS[score]
+}
+
+// Returns true if the given check recorded a failure.
+func (result *Result) failed(check string) bool {
+	for _, failure := range result.Failures {
+		if failure.Check == check {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the highest score level whose cutoff is satisfied.
+func scoreLevel(satisfied func(int) bool) int {
+	level := 0
+
+	for i := 0; i < MAX_SCORE; i++ {
+		if !satisfied(i) {
+			break
+		}
+
+		level = i + 1
+	}
+
+	return level
+}
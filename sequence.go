@@ -0,0 +1,237 @@
+package valpass
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// Sequence describes a sequence or repetition found in a password.
+type Sequence struct {
+	Kind   string // one of the SEQUENCE_* constants
+	Token  string // the part of the password forming the sequence
+	Start  int    // position of the first character, counted in characters
+	Length int    // number of characters
+}
+
+// Kinds of sequences.
+const (
+	SEQUENCE_ASCENDING  string = "ascending"  // e.g. "abcd" or "1234"
+	SEQUENCE_DESCENDING string = "descending" // e.g. "dcba" or "4321"
+	SEQUENCE_REPEAT     string = "repeat"     // e.g. "aaaa"
+	SEQUENCE_BLOCK      string = "block"      // e.g. "abcabc"
+	SEQUENCE_MIRROR     string = "mirror"     // e.g. "abccba"
+)
+
+const (
+	MIN_SEQUENCE int = 4
+
+	// sequences shorter than this are not being reported at all
+	sequence_min_len int = 3
+
+	// blocks and mirrors shorter than this are not being reported at
+	// all, they occur way too often in natural language, e.g. "erer"
+	block_min_len  int = 6
+	mirror_min_len int = 6
+)
+
+/*
+Return all  sequences and repetitions  found in the password,  sorted
+by position. Unlike  the compression metric, this  also works for
+short passwords.
+*/
+func getSequences(passphrase string) []Sequence {
+	chars := []rune(passphrase)
+
+	// we don't care about case: "aBcD" is still a sequence
+	lower := make([]rune, len(chars))
+	for i, char := range chars {
+		lower[i] = unicode.ToLower(char)
+	}
+
+	sequences := []Sequence{}
+	sequences = append(sequences, getRuns(chars, lower)...)
+	sequences = append(sequences, getBlocks(chars, lower)...)
+	sequences = append(sequences, getMirrors(chars, lower)...)
+
+	sort.SliceStable(sequences, func(i, j int) bool {
+		return sequences[i].Start < sequences[j].Start
+	})
+
+	return sequences
+}
+
+// Find runs of chars with a constant code point delta of -1, 0 or 1.
+func getRuns(chars, lower []rune) []Sequence {
+	sequences := []Sequence{}
+
+	for start := 0; start < len(chars)-1; {
+		delta := lower[start+1] - lower[start]
+		end := start + 2
+
+		if delta < -1 || delta > 1 {
+			start++
+			continue
+		}
+
+		for end < len(chars) && lower[end]-lower[end-1] == delta {
+			end++
+		}
+
+		if end-start >= sequence_min_len {
+			kind := SEQUENCE_REPEAT
+
+			switch delta {
+			case 1:
+				kind = SEQUENCE_ASCENDING
+			case -1:
+				kind = SEQUENCE_DESCENDING
+			}
+
+			sequences = append(sequences, newSequence(kind, chars, start, end))
+		}
+
+		// the last char of a run may start the next one, e.g. "abcba"
+		start = end - 1
+	}
+
+	return sequences
+}
+
+// Find blocks of at least 2 chars which are repeated, e.g. "abcabc".
+func getBlocks(chars, lower []rune) []Sequence {
+	sequences := []Sequence{}
+
+	for start := 0; start < len(chars); {
+		best := 0
+
+		// blocks within a run of one char are a run, not a block, so
+		// only sizes beyond it are considered
+		run := 1
+		for start+run < len(chars) && lower[start+run] == lower[start] {
+			run++
+		}
+
+		for size := Max(2, run+1); start+2*size <= len(chars); size++ {
+			end := start + size
+			for end+size <= len(chars) && equalRunes(lower[end:end+size], lower[start:start+size]) {
+				end += size
+			}
+
+			if end-start > size && end-start >= block_min_len && end-start > best {
+				best = end - start
+			}
+		}
+
+		if best > 0 {
+			sequences = append(sequences, newSequence(SEQUENCE_BLOCK, chars, start, start+best))
+			start += best
+			continue
+		}
+
+		start++
+	}
+
+	return sequences
+}
+
+// Find palindromes, e.g. "abccba" or "abcba".
+func getMirrors(chars, lower []rune) []Sequence {
+	sequences := []Sequence{}
+
+	// expand around every center, odd and even lengths
+	for center := 0; center < 2*len(chars)-1; center++ {
+		left := center / 2
+		right := left + center%2
+
+		for left >= 0 && right < len(chars) && lower[left] == lower[right] {
+			left--
+			right++
+		}
+
+		start, end := left+1, right
+		if end-start < mirror_min_len || isRepeat(lower[start:end]) {
+			continue
+		}
+
+		if containedIn(sequences, start, end) {
+			continue
+		}
+
+		sequences = append(sequences, newSequence(SEQUENCE_MIRROR, chars, start, end))
+	}
+
+	return sequences
+}
+
+// Returns true if start..end is part of one of the sequences.
+func containedIn(sequences []Sequence, start, end int) bool {
+	for _, sequence := range sequences {
+		if start >= sequence.Start && end <= sequence.Start+sequence.Length {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newSequence(kind string, chars []rune, start, end int) Sequence {
+	return Sequence{
+		Kind:   kind,
+		Token:  string(chars[start:end]),
+		Start:  start,
+		Length: end - start,
+	}
+}
+
+// Returns true if all chars are the same.
+func isRepeat(chars []rune) bool {
+	for _, char := range chars {
+		if char != chars[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the longest sequence, the zero sequence if there are none.
+func longestSequence(sequences []Sequence) Sequence {
+	var longest Sequence
+
+	for _, sequence := range sequences {
+		if sequence.Length > longest.Length {
+			longest = sequence
+		}
+	}
+
+	return longest
+}
+
+// Returns a human readable description of the sequence.
+func (sequence Sequence) message() string {
+	switch sequence.Kind {
+	case SEQUENCE_REPEAT:
+		return fmt.Sprintf("password contains the repeated character %q", sequence.Token)
+	case SEQUENCE_BLOCK:
+		return fmt.Sprintf("password contains the repeated block %q", sequence.Token)
+	case SEQUENCE_MIRROR:
+		return fmt.Sprintf("password contains the mirrored string %q", sequence.Token)
+	default:
+		return fmt.Sprintf("password contains the sequence %q", sequence.Token)
+	}
+}
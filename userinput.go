@@ -0,0 +1,255 @@
+package valpass
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// UserInputMatch describes a user input found in a password.
+type UserInputMatch struct {
+	Input         string            // the user input as given in Options.UserInputs
+	Token         string            // the lower cased part of the input which matched
+	Kind          string            // how it matched, one of the USERINPUT_* constants
+	Distance      int               // Levenshtein distance for fuzzy matches
+	Substitutions map[string]string // l33t substitutions undone to find the match, if any
+}
+
+const (
+	USERINPUT_EXACT     string = "exact"     // the password is the token
+	USERINPUT_SUBSTRING string = "substring" // the password contains the token
+	USERINPUT_REVERSED  string = "reversed"  // the password contains the reversed token
+	USERINPUT_FUZZY     string = "fuzzy"     // the password contains something similar to the token
+
+	// shorter tokens produce too many false positives, e.g. "com"
+	user_input_min_token int = 4
+
+	// fuzzy matching compares every token to every part of the password,
+	// so only this many tokens of at most this length are matched fuzzy,
+	// the others only exactly
+	user_input_max_fuzzy       int = 16
+	user_input_max_fuzzy_token int = 24
+)
+
+// userToken is a part of a user input.
+type userToken struct {
+	input string
+	token string
+}
+
+/*
+Split the user inputs into tokens: every input as a whole, the local
+part and domain of email addresses, all words made of letters and
+digits and  these words joined  together. Tokens are lower  cased, those
+shorter  than  user_input_min_token  are  ignored. The  result  is sorted
+by length, longest first.
+*/
+func getUserTokens(inputs []string) []userToken {
+	tokens := []userToken{}
+	seen := map[string]bool{}
+
+	add := func(input, token string) {
+		token = strings.ToLower(strings.TrimSpace(token))
+
+		if utf8.RuneCountInString(token) >= user_input_min_token && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, userToken{input: input, token: token})
+		}
+	}
+
+	for _, input := range inputs {
+		add(input, input)
+
+		if local, domain, ok := strings.Cut(input, "@"); ok {
+			add(input, local)
+			add(input, domain)
+		}
+
+		words := strings.FieldsFunc(input, func(char rune) bool {
+			return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+		})
+
+		add(input, strings.Join(words, ""))
+
+		for _, word := range words {
+			add(input, word)
+		}
+	}
+
+	sort.SliceStable(tokens, func(i, j int) bool {
+		return utf8.RuneCountInString(tokens[i].token) > utf8.RuneCountInString(tokens[j].token)
+	})
+
+	return tokens
+}
+
+// userFuzzy contains the thresholds for fuzzy user input matches, see
+// fuzzyAcceptable().
+type userFuzzy struct {
+	maxdist int
+	minsim  float64
+}
+
+/*
+Returns the l33t table and fuzzy thresholds for user inputs. They are
+taken from the dictionary if there is one, otherwise LEET_TABLE and
+MIN_FUZZY_SIMILARITY are being used. The default MAX_FUZZY_DISTANCE is
+not, a fixed number of edits is too lax for short tokens.
+*/
+func getUserSettings(dict *Dictionary) (map[rune][]rune, userFuzzy) {
+	fuzzy := userFuzzy{minsim: MIN_FUZZY_SIMILARITY}
+
+	if dict == nil {
+		return nil, fuzzy
+	}
+
+	if dict.MaxDistance != 0 || dict.MinSimilarity != 0 {
+		fuzzy = userFuzzy{maxdist: dict.MaxDistance, minsim: dict.MinSimilarity}
+	}
+
+	return dict.LeetTable, fuzzy
+}
+
+/*
+Return all user inputs found  in the password. The lower cased password
+is searched  for every token,  also reversed and with  l33t substitutions
+of the dictionary  undone. Tokens without an exact  or substring match
+are compared  to all parts of  the password of similar  length, these
+match if they reach the fuzzy thresholds of the dictionary. This is
+limited to the  first user_input_max_fuzzy tokens no  longer than
+user_input_max_fuzzy_token chars. Tokens contained in already matched
+ones are not reported again.
+*/
+func getUserInputMatches(passphrase string, inputs []string, dict *Dictionary) []UserInputMatch {
+	matches := []UserInputMatch{}
+
+	lcpass := strings.ToLower(passphrase)
+	if lcpass == "" {
+		return matches
+	}
+
+	table, fuzzy := getUserSettings(dict)
+
+	candidates := []leetCandidate{{word: lcpass}}
+	candidates = append(candidates, getLeetCandidates(lcpass, table)...)
+	fuzzycount := 0
+
+	for _, token := range getUserTokens(inputs) {
+		covered := false
+		for _, match := range matches {
+			if strings.Contains(match.Token, token.token) {
+				covered = true
+				break
+			}
+		}
+
+		if covered {
+			continue
+		}
+
+		usefuzzy := fuzzycount < user_input_max_fuzzy &&
+			utf8.RuneCountInString(token.token) <= user_input_max_fuzzy_token
+		if usefuzzy {
+			fuzzycount++
+		}
+
+		if match, ok := findUserToken(candidates, token, fuzzy, usefuzzy); ok {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches
+}
+
+// Look up a single token in the password candidates, also fuzzy if
+// usefuzzy is true.
+func findUserToken(candidates []leetCandidate, token userToken, fuzzy userFuzzy, usefuzzy bool) (UserInputMatch, bool) {
+	match := UserInputMatch{Input: token.input, Token: token.token}
+	reversed := reverseString(token.token)
+
+	for _, candidate := range candidates {
+		match.Substitutions = candidate.substitutions
+
+		switch {
+		case candidate.word == token.token:
+			match.Kind = USERINPUT_EXACT
+		case strings.Contains(candidate.word, token.token):
+			match.Kind = USERINPUT_SUBSTRING
+		case strings.Contains(candidate.word, reversed):
+			match.Kind = USERINPUT_REVERSED
+		default:
+			continue
+		}
+
+		return match, true
+	}
+
+	if !usefuzzy {
+		return match, false
+	}
+
+	// fuzzy matching only on the password itself, l33t candidates would
+	// multiply the costs
+	match.Substitutions = nil
+	if distance, ok := fuzzyUserToken(candidates[0].word, token.token, fuzzy); ok {
+		match.Kind = USERINPUT_FUZZY
+		match.Distance = distance
+
+		return match, true
+	}
+
+	return match, false
+}
+
+/*
+Compare the token to all parts of the password whose length differs by
+at most one character, returns the smallest distance of an acceptable
+match.
+*/
+func fuzzyUserToken(lcpass, token string, fuzzy userFuzzy) (int, bool) {
+	chars := []rune(lcpass)
+	tokenlen := utf8.RuneCountInString(token)
+	metric := NewLevenshtein()
+
+	best := -1
+
+	for size := tokenlen - 1; size <= tokenlen+1; size++ {
+		for start := 0; start+size <= len(chars) && size > 0; start++ {
+			distance := metric.Distance(string(chars[start:start+size]), token)
+			maxlen := Max(size, tokenlen)
+
+			if fuzzyAcceptable(distance, maxlen, fuzzy.maxdist, fuzzy.minsim) && (best == -1 || distance < best) {
+				best = distance
+			}
+		}
+	}
+
+	return best, best != -1
+}
+
+// Returns all user tokens as a dictionary, used by the guess estimator.
+func getUserDictionary(inputs []string, table map[rune][]rune) *Dictionary {
+	dict := &Dictionary{Leet: true, LeetTable: table}
+
+	for _, token := range getUserTokens(inputs) {
+		dict.Words = append(dict.Words, token.token)
+	}
+
+	return dict
+}
+
+// Returns a human readable description of the match.
+func (match UserInputMatch) message() string {
+	switch match.Kind {
+	case USERINPUT_EXACT:
+		return fmt.Sprintf("password is based on the user input %q", match.Input)
+	case USERINPUT_REVERSED:
+		return fmt.Sprintf("password contains the reversed user input %q", match.Input)
+	case USERINPUT_FUZZY:
+		return fmt.Sprintf("password contains something similar to the user input %q", match.Input)
+	default:
+		return fmt.Sprintf("password contains the user input %q", match.Input)
+	}
+}
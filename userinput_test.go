@@ -0,0 +1,178 @@
+package valpass_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var user_inputs = []string{"jdoe", "John Doe", "john.doe@example.com", "Acme Corporation"}
+
+var pass_userinput_bad = []string{
+	`jdoe`, `John1984!`, `eodnhoj`, `j0hnd0e`, `Ex4mple!2024`,
+	`Corporati0n`, `korporation77`, `JOHN.DOE@EXAMPLE.COM`,
+}
+
+var opts_userinput = valpass.Options{
+	UserInputs: user_inputs,
+}
+
+func TestUserInputs(t *testing.T) {
+	t.Parallel()
+
+	for _, pass := range pass_userinput_bad {
+		CheckPassword(t, pass, Test{name: "checkbad-userinput", want: false, opts: opts_userinput})
+	}
+
+	for _, pass := range append(pass_random_good, pass_diceware_good...) {
+		CheckPassword(t, pass, Test{name: "checkgood-userinput", want: true, opts: opts_userinput})
+	}
+}
+
+func TestUserInputMatches(t *testing.T) {
+	t.Parallel()
+
+	var inputtests = []struct {
+		pass  string
+		input string
+		token string
+		kind  string
+	}{
+		{`jdoe`, "jdoe", "jdoe", valpass.USERINPUT_EXACT},
+		{`xJohn1984!`, "John Doe", "john", valpass.USERINPUT_SUBSTRING},
+		{`eodnhoj`, "John Doe", "johndoe", valpass.USERINPUT_REVERSED},
+		{`3xampl3.c0m`, "john.doe@example.com", "example.com", valpass.USERINPUT_EXACT},
+		{`korporation77`, "Acme Corporation", "corporation", valpass.USERINPUT_FUZZY},
+	}
+
+	for _, tt := range inputtests {
+		result, err := valpass.Validate(tt.pass, opts_userinput)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		if len(result.UserInputMatches) == 0 {
+			t.Fatalf("no user input found in %s", tt.pass)
+		}
+
+		match := result.UserInputMatches[0]
+		if match.Input != tt.input || match.Token != tt.token || match.Kind != tt.kind {
+			t.Errorf("unexpected user input match in %s. want: %s/%s/%s, got: %v",
+				tt.pass, tt.input, tt.token, tt.kind, match)
+		}
+
+		if result.Failures[0].Check != valpass.CHECK_USERINPUT {
+			t.Errorf("unexpected failure: %v", result.Failures)
+		}
+	}
+
+	// user inputs make the guess estimator aware of them
+	opts := valpass.Options{GuessesLog10: 1}
+
+	without, err := valpass.Validate(`Corporati0n!`, opts)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	opts.UserInputs = user_inputs
+
+	with, err := valpass.Validate(`Corporati0n!`, opts)
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if with.Guesses >= without.Guesses {
+		t.Errorf("user inputs not used by the guess estimator. with: %0.2f, without: %0.2f",
+			with.GuessesLog10, without.GuessesLog10)
+	}
+}
+
+func TestUserInputSettings(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{"Johndoe"}
+
+	// the l33t table and fuzzy thresholds of the dictionary are used
+	custom := valpass.Options{
+		UserInputs: inputs,
+		Dictionary: &valpass.Dictionary{
+			Words:         dict_compiled.Words,
+			LeetTable:     map[rune][]rune{'#': {'h'}},
+			MinSimilarity: 0.9,
+		},
+	}
+
+	var settingstests = []struct {
+		pass string
+		opts valpass.Options
+		kind string
+	}{
+		{`jo#ndoe`, valpass.Options{UserInputs: inputs}, valpass.USERINPUT_FUZZY},
+		{`jo#ndoe`, custom, valpass.USERINPUT_EXACT},
+		{`j0hnd0e`, valpass.Options{UserInputs: inputs}, valpass.USERINPUT_EXACT},
+		{`j0hnd0e`, custom, ""},
+		{`johndie`, valpass.Options{UserInputs: inputs}, valpass.USERINPUT_FUZZY},
+		{`johndie`, custom, ""},
+	}
+
+	for _, tt := range settingstests {
+		result, err := valpass.Validate(tt.pass, tt.opts)
+		if err != nil {
+			t.Fatalf("validation failed with error: %s", err)
+		}
+
+		kind := ""
+		if len(result.UserInputMatches) > 0 {
+			kind = result.UserInputMatches[0].Kind
+		}
+
+		if kind != tt.kind {
+			t.Errorf("unexpected user input match in %s. want: %q, got: %v",
+				tt.pass, tt.kind, result.UserInputMatches)
+		}
+	}
+}
+
+// Only a few short tokens are compared fuzzy to the password, so many
+// long user inputs cost about as much as a single one. Allocations are
+// counted instead of timing it, therefore the test does not run in
+// parallel.
+func TestUserInputsWork(t *testing.T) {
+	pass := strings.Join(pass_random_good, "")[:256]
+
+	inputs := make([]string, 16)
+	for i := range inputs {
+		words := make([]string, 12)
+		for j := range words {
+			words[j] = fmt.Sprintf("%c%019d", 'a'+j, i)
+		}
+
+		inputs[i] = strings.Join(words, " ")
+	}
+
+	validate := func(inputs []string) func() {
+		return func() {
+			if _, err := valpass.Validate(pass, valpass.Options{UserInputs: inputs}); err != nil {
+				t.Fatalf("validation failed with error: %s", err)
+			}
+		}
+	}
+
+	want := testing.AllocsPerRun(1, validate(user_inputs)) * 4
+
+	if got := testing.AllocsPerRun(1, validate(inputs)); got > want {
+		t.Errorf("user input matching needs too much work: %.0f allocations, want at most %.0f", got, want)
+	}
+
+	// tokens too long for fuzzy matching are still found exactly
+	result, err := valpass.Validate(`x`+inputs[0]+`!`, valpass.Options{UserInputs: inputs})
+	if err != nil {
+		t.Fatalf("validation failed with error: %s", err)
+	}
+
+	if result.Ok || result.UserInputMatches[0].Kind != valpass.USERINPUT_SUBSTRING {
+		t.Errorf("long user input not found: %v", result.UserInputMatches)
+	}
+}
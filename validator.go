@@ -0,0 +1,195 @@
+package valpass
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOptions is returned (wrapped) if the options can't be used,
+// e.g. because of a negative threshold.
+var ErrInvalidOptions = errors.New("invalid options")
+
+/*
+Validator validates passwords using a fixed set of options. The options
+are checked once on creation and expensive resources like dictionary
+indices are being built up front. A Validator is safe for concurrent
+use by as many goroutines as you like, as long as the BreachChecker and
+HistoryStore you supply are.
+*/
+type Validator struct {
+	options Options
+	builtin []Check // the built-in checks enabled in options
+}
+
+/*
+NewValidator checks the options and returns a new Validator. Unlike
+Validate(), a zero Options struct turns off all checks, start with
+DefaultOptions() if you want the defaults.
+
+An uncompiled dictionary is being copied and compiled, the original is
+left untouched. Don't modify the dictionary words afterwards.
+*/
+func NewValidator(options Options) (*Validator, error) {
+	if err := checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	if options.Dictionary != nil && !options.Dictionary.Compiled() {
+		dict := *options.Dictionary
+		if err := dict.Compile(); err != nil {
+			return nil, err
+		}
+
+		options.Dictionary = &dict
+	}
+
+	return newValidator(options), nil
+}
+
+// Returns a Validator for the checked options, without compiling
+// anything.
+func newValidator(options Options) *Validator {
+	if options.KeyboardLayouts != nil {
+		options.KeyboardLayouts = append([]string{}, options.KeyboardLayouts...)
+	}
+
+	if options.UserInputs != nil {
+		options.UserInputs = append([]string{}, options.UserInputs...)
+	}
+
+	if options.Checks != nil {
+		options.Checks = append([]Check{}, options.Checks...)
+	}
+
+	if options.Compressors != nil {
+		options.Compressors = append([]Compressor{}, options.Compressors...)
+	}
+
+	builtin := BuiltinChecks(options)
+	if options.Builtin != nil {
+		options.Builtin = append([]Check{}, options.Builtin...)
+		builtin = options.Builtin
+	}
+
+	return &Validator{options: options, builtin: builtin}
+}
+
+// Options returns a copy of the options of the Validator.
+func (validator *Validator) Options() Options {
+	return validator.options
+}
+
+// Returns an error if the options are inconsistent or out of range.
+func checkOptions(options Options) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, fmt.Sprintf(format, args...))
+	}
+
+	thresholds := []struct {
+		name  string
+		value float64
+	}{
+		{"Compress", float64(options.Compress)},
+		{"Explained", float64(options.Explained)},
+		{"CharDistribution", options.CharDistribution},
+		{"Entropy", options.Entropy},
+		{"TotalEntropy", options.TotalEntropy},
+		{"PoolEntropy", options.PoolEntropy},
+		{"AlphabetSize", float64(options.AlphabetSize)},
+		{"BreachMinCount", float64(options.BreachMinCount)},
+		{"MinLength", float64(options.MinLength)},
+		{"MaxLength", float64(options.MaxLength)},
+		{"MinClasses", float64(options.MinClasses)},
+		{"KeyboardWalk", float64(options.KeyboardWalk)},
+		{"Sequence", float64(options.Sequence)},
+		{"MinYear", float64(options.MinYear)},
+		{"MaxYear", float64(options.MaxYear)},
+		{"GuessesLog10", options.GuessesLog10},
+		{"PassphraseWords", float64(options.PassphraseWords)},
+		{"PassphraseWordLength", float64(options.PassphraseWordLength)},
+		{"PassphraseEntropy", options.PassphraseEntropy},
+	}
+
+	for _, threshold := range thresholds {
+		if threshold.value < 0 {
+			return invalid("%s must not be negative", threshold.name)
+		}
+	}
+
+	switch {
+	case options.Compress > 100:
+		return invalid("Compress must not be higher than 100%%")
+	case options.Explained > 100:
+		return invalid("Explained must not be higher than 100%%")
+	case options.CharDistribution > 100:
+		return invalid("CharDistribution must not be higher than 100%%")
+	case options.MinClasses > 4:
+		return invalid("MinClasses must not be higher than 4")
+	case options.MaxLength > 0 && options.MinLength > options.MaxLength:
+		return invalid("MinLength must not be higher than MaxLength")
+	case options.MinYear > 0 && options.MaxYear > 0 && options.MinYear > options.MaxYear:
+		return invalid("MinYear must not be higher than MaxYear")
+	case options.Passphrase && options.Dictionary == nil:
+		return invalid("Passphrase requires a Dictionary")
+	}
+
+	if dict := options.Dictionary; dict != nil {
+		switch {
+		case len(dict.Words) < MIN_DICT_LEN:
+			return invalid("provided dictionary is too small, it must contain at least %d words", MIN_DICT_LEN)
+		case dict.MaxDistance < 0:
+			return invalid("Dictionary.MaxDistance must not be negative")
+		case dict.MinSimilarity < 0 || dict.MinSimilarity > 1:
+			return invalid("Dictionary.MinSimilarity must be between 0 and 1")
+		}
+	}
+
+	for i, check := range options.Checks {
+		if check == nil {
+			return invalid("Checks[%d] is nil", i)
+		}
+	}
+
+	for i, check := range options.Builtin {
+		if check == nil {
+			return invalid("Builtin[%d] is nil", i)
+		}
+	}
+
+	compressors := map[string]bool{}
+	for i, compressor := range options.Compressors {
+		if compressor == nil {
+			return invalid("Compressors[%d] is nil", i)
+		}
+
+		if compressors[compressor.Name()] {
+			return invalid("Compressors contains %s more than once", compressor.Name())
+		}
+
+		level := 0
+		switch compressor := compressor.(type) {
+		case FlateCompressor:
+			level = compressor.Level
+		case ZlibCompressor:
+			level = compressor.Level
+		case GzipCompressor:
+			level = compressor.Level
+		}
+
+		if level < 0 || level > flate.BestCompression {
+			return invalid("Compressors[%d] has level %d, it must be between 1 and 9, or zero for the default",
+				i, level)
+		}
+
+		compressors[compressor.Name()] = true
+	}
+
+	if options.KeyboardWalk > 0 {
+		if _, err := getKeyboardGraphs(options.KeyboardLayouts); err != nil {
+			return invalid("%s", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package valpass_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/tlinden/valpass"
+)
+
+var opts_invalid = map[string]valpass.Options{
+	"negative-entropy":   {Entropy: -1},
+	"negative-length":    {MinLength: -8},
+	"compress-too-high":  {Compress: 101},
+	"dist-too-high":      {CharDistribution: 100.5},
+	"too-many-classes":   {MinClasses: 5},
+	"min-over-max":       {MinLength: 20, MaxLength: 10},
+	"year-range":         {MinYear: 2000, MaxYear: 1990},
+	"small-dictionary":   opts_invaliddict,
+	"invalid-similarity": {Dictionary: &valpass.Dictionary{Words: opts_dict.Dictionary.Words, MinSimilarity: 2}},
+	"unknown-layout":     {KeyboardWalk: 4, KeyboardLayouts: []string{"dvorak-nonexistent"}},
+	"passphrase-no-dict": {Passphrase: true},
+	"negative-words":     {Passphrase: true, Dictionary: opts_dict.Dictionary, PassphraseWords: -1},
+}
+
+func TestValidatorOptions(t *testing.T) {
+	t.Parallel()
+
+	for name, opts := range opts_invalid {
+		validator, err := valpass.NewValidator(opts)
+		if err == nil || validator != nil || !errors.Is(err, valpass.ErrInvalidOptions) {
+			t.Errorf("%s: invalid options not detected: %v", name, err)
+		}
+
+		if _, err := valpass.Validate(`oIsCdyd7rP8oNmH29y8OmBdeq1ABAqKAFHHWr87v`, opts); err == nil {
+			t.Errorf("%s: invalid options not detected by Validate()", name)
+		}
+	}
+
+	if _, err := valpass.Validate(`clock`, opts_std, opts_dict); !errors.Is(err, valpass.ErrInvalidOptions) {
+		t.Errorf("more than one Options argument not detected: %v", err)
+	}
+
+	validator, err := valpass.NewValidator(valpass.Options{})
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	result, err := validator.Validate(`clock`)
+	if err != nil || !result.Ok {
+		t.Errorf("validator without checks failed: %v %v", err, result)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+
+	dict := &valpass.Dictionary{Words: opts_dict.Dictionary.Words, Submatch: true}
+
+	opts := valpass.DefaultOptions()
+	opts.Dictionary = dict
+
+	validator, err := valpass.NewValidator(opts)
+	if err != nil {
+		t.Fatalf("failed to create validator: %s", err)
+	}
+
+	if dict.Compiled() || !validator.Options().Dictionary.Compiled() {
+		t.Errorf("the validator must compile a copy of the dictionary")
+	}
+
+	var wg sync.WaitGroup
+
+	for _, pass := range append(pass_dict_bad, pass_dictsub_bad...) {
+		wg.Add(1)
+
+		go func(pass string) {
+			defer wg.Done()
+
+			result, err := validator.Validate(pass)
+			if err != nil || result.Ok {
+				t.Errorf("concurrent validation of %s failed: %v %v", pass, err, result)
+			}
+		}(pass)
+	}
+
+	for _, pass := range pass_random_good {
+		wg.Add(1)
+
+		go func(pass string) {
+			defer wg.Done()
+
+			result, err := validator.Validate(pass)
+			if err != nil || !result.Ok {
+				t.Errorf("concurrent validation of %s failed: %v %v", pass, err, result)
+			}
+		}(pass)
+	}
+
+	wg.Wait()
+}